@@ -2,9 +2,12 @@ package chathttpadapter
 
 import (
 	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,78 +16,186 @@ import (
 
 type TravelHandler struct {
 	orchestrator *application.MultiAgentOrchestrator
+	history      *application.ConversationHistory
+	runs         *runRegistry
 }
 
-func NewTravelHandler(orchestrator *application.MultiAgentOrchestrator) *TravelHandler {
-	return &TravelHandler{orchestrator: orchestrator}
+func NewTravelHandler(orchestrator *application.MultiAgentOrchestrator, history *application.ConversationHistory) *TravelHandler {
+	return &TravelHandler{orchestrator: orchestrator, history: history, runs: newRunRegistry()}
 }
 
 func (h *TravelHandler) RegisterRoutes(app *fiber.App) {
 	travelGroup := app.Group("/travel")
 	travelGroup.Post("/recommendation", h.multiAgentRecomendation)
+	travelGroup.Get("/recommendation/:runId/events", h.resumeRun)
+	travelGroup.Get("/conversations/:id", h.getConversation)
+	travelGroup.Get("/events/schema", h.getEventSchema)
+	travelGroup.Get("/runs/outcomes", h.getRunOutcomes)
+}
+
+// getRunOutcomes serves the travel_runs counters, e.g. {"completed": 12,
+// "failed": 1}, and nothing else of the process's published variables.
+func (h *TravelHandler) getRunOutcomes(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.SendString(runOutcomes.String())
+}
+
+func (h *TravelHandler) getConversation(c *fiber.Ctx) error {
+	convoID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid conversation ID", err.Error())
+	}
+
+	userID, err := uuid.Parse(c.Query("userId"))
+	if err != nil {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID", "userId query parameter must be a UUID")
+	}
+
+	view, err := h.history.Get(c.UserContext(), convoID, userID)
+	switch {
+	case errors.Is(err, application.ErrConversationNotFound):
+		return FormatErrorResponse(c, fiber.StatusNotFound, "Conversation not found", convoID.String())
+	case errors.Is(err, application.ErrConversationNotOwned):
+		return FormatErrorResponse(c, fiber.StatusForbidden, "Conversation belongs to another user", convoID.String())
+	case err != nil:
+		return FormatErrorResponse(c, fiber.StatusInternalServerError, "Could not load conversation", err.Error())
+	}
+
+	return c.JSON(toConversationResponseDTO(view))
 }
 
 func (h *TravelHandler) multiAgentRecomendation(c *fiber.Ctx) error {
-	c.Set("Content-Type", "text/event-stream")
-	c.Set("Cache-Control", "no-cache")
-	c.Set("Connection", "keep-alive")
+	if lastEventID := c.Get("Last-Event-ID"); lastEventID != "" {
+		return h.resume(c, lastEventID)
+	}
 
 	req, err := parseRequest(c)
+	if errors.Is(err, errRequestRejected) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+	convoID, err := uuid.Parse(req.ConversationID)
+	if err != nil {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid conversation ID", err.Error())
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
+	}
+
+	orchInput := application.OrchestratorInput{
+		ConversationID: convoID,
+		UserID:         userID,
+		Role:           req.Message.Role,
+		Content:        req.Message.Content,
+		Currency:       domain.Currency(req.Currency),
+	}
+	if locale, ok := domain.ParseAcceptLanguage(c.Get("Accept-Language")); ok {
+		orchInput.Locale = locale
+	}
+
+	// The run is detached from the request so it keeps going, and keeps
+	// buffering events, while a dropped client reconnects. A client that does
+	// not come back gets the run cancelled (see runStream.unsubscribe).
+	run := h.runs.start()
+	go func() {
+		defer run.finish()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 		defer cancel()
 
-		writeEvent := func(eventType, data string) error {
-			if _, err := fmt.Fprintf(w, "event: %s\n", eventType); err != nil {
-				return err
-			}
-			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
-				return err
+		started := time.Now()
+		err := h.orchestrator.Run(run.context(ctx), orchInput, run.publish)
+		outcome := recordOutcome(err)
+		log.Printf("run %s for conversation %s: %s after %s", run.id, convoID, outcome, time.Since(started).Round(time.Millisecond))
+		// The orchestrator reports most failures itself, and clients stop at the
+		// first error event, so only the failures it did not report are sent.
+		if err != nil && outcome != outcomeCancelled {
+			log.Printf("run %s: %v", run.id, err)
+		}
+		if err != nil && outcome != outcomeCancelled && !run.reportedFailure() {
+			code := application.CodeInternal
+			if outcome == outcomeTimedOut {
+				code = application.CodeInterrupted
 			}
-			return w.Flush()
+			_ = run.publish(application.Event{
+				Type:  application.EventError,
+				Phase: application.PhaseFailed,
+				Code:  code,
+				Text:  fmt.Sprintf("Error: %v", err),
+			})
 		}
+	}()
 
-		convoID, err := uuid.Parse(req.ConversationID)
-		if err != nil {
-			_ = writeEvent("error", "Invalid conversation ID")
-			return
-		}
+	return h.streamRun(c, run, 0)
+}
 
-		userID, err := uuid.Parse(req.UserID)
-		if err != nil {
-			_ = writeEvent("error", "Invalid user ID")
-			return
-		}
+// resumeRun is the EventSource-friendly way to reconnect to a run: browsers
+// resend Last-Event-ID on the URL they were following.
+func (h *TravelHandler) resumeRun(c *fiber.Ctx) error {
+	lastEventID := c.Get("Last-Event-ID", c.Query("lastEventId"))
+	if lastEventID == "" {
+		lastEventID = c.Params("runId") + ":0"
+	}
+	return h.resume(c, lastEventID)
+}
 
-		orchInput := application.OrchestratorInput{
-			ConversationID: convoID,
-			UserID:         userID,
-			Role:           req.Message.Role,
-			Content:        req.Message.Content,
-		}
+// resume replays the events after lastEventID and then follows the run live if
+// it is still going.
+func (h *TravelHandler) resume(c *fiber.Ctx, lastEventID string) error {
+	runID, lastSeq, err := parseEventID(lastEventID)
+	if err != nil {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid Last-Event-ID", err.Error())
+	}
+	if param := c.Params("runId"); param != "" && param != runID {
+		return FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid Last-Event-ID", "event ID belongs to another run")
+	}
 
-		err = h.orchestrator.Run(ctx, orchInput, writeEvent)
-		if err != nil {
-			_ = writeEvent("error", fmt.Sprintf("Error: %v", err))
-		}
+	run, ok := h.runs.get(runID)
+	if !ok {
+		return FormatErrorResponse(c, fiber.StatusGone, "Run not found or expired", runID)
+	}
+
+	return h.streamRun(c, run, lastSeq)
+}
+
+func (h *TravelHandler) streamRun(c *fiber.Ctx, run *runStream, lastSeq uint64) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Run-ID", run.id)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		_ = follow(w, run, lastSeq)
 	})
 
 	return nil
 }
 
+// errRequestRejected is returned by parseRequest once the error response has
+// already been written.
+var errRequestRejected = errors.New("request rejected")
+
 func parseRequest(c *fiber.Ctx) (ChatRequestDTO, error) {
 	var req ChatRequestDTO
 	if err := c.BodyParser(&req); err != nil {
-		return req, FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+		return req, reject(FormatErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err.Error()))
 	}
 
 	if validationErrors := req.Validate(); len(validationErrors) > 0 {
-		return req, FormatErrorResponse(c, fiber.StatusBadRequest, "Validation failed", validationErrors)
+		return req, reject(FormatErrorResponse(c, fiber.StatusBadRequest, "Validation failed", validationErrors))
 	}
 
 	return req, nil
 }
+
+func reject(writeErr error) error {
+	if writeErr != nil {
+		return writeErr
+	}
+	return errRequestRejected
+}
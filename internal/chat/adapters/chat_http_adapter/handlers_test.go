@@ -0,0 +1,247 @@
+package chathttpadapter
+
+import (
+	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/adapters/repository"
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConversation(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+
+	userID := uuid.New()
+	chat := domain.NewChatWithID(uuid.New(), userID)
+	require.NoError(t, store.Save(ctx, chat))
+	question := domain.NewUserMessage(chat.ID, "I love hiking, Peru or Chile?")
+	require.NoError(t, store.AppendMessages(ctx, chat.ID, userID, question))
+
+	destinationExpert, _ := application.TravelAgents().Definition(domain.DestinationExpert)
+	agentChat, err := domain.NewAgentSessionFromInjection(domain.DestinationExpert, userID, domain.Injection{
+		Agent:  destinationExpert,
+		Values: map[string]string{"interest": "hiking", "destination": "Peru, Chile", "language": "English"},
+	})
+	require.NoError(t, err)
+	session := domain.NewAgentSession(chat.ID, question.ID, domain.DestinationExpert, map[string]string{"interest": "hiking"})
+	session.TemplateVersion = destinationExpert.TemplateVersion
+	session.Finish(agentChat, "Visit Torres del Paine", nil)
+	require.NoError(t, store.SaveAgentSession(ctx, userID, session))
+
+	failed := domain.NewAgentSession(chat.ID, question.ID, domain.BudgetPlanner, nil)
+	failed.Finish(nil, "", errors.New("rate limited"))
+	require.NoError(t, store.SaveAgentSession(ctx, userID, failed))
+
+	app := fiber.New()
+	NewTravelHandler(nil, application.NewConversationHistory(store, store)).RegisterRoutes(app)
+
+	t.Run("returns transcript and agent sessions", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/travel/conversations/"+chat.ID.String()+"?userId="+userID.String(), nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body ConversationResponseDTO
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, chat.ID.String(), body.ConversationID)
+		require.Len(t, body.Messages, 1)
+		assert.Equal(t, "user", body.Messages[0].Role)
+
+		require.Len(t, body.AgentSessions, 2)
+		dest := body.AgentSessions[0]
+		assert.Equal(t, "destination_expert", dest.Agent)
+		assert.Equal(t, destinationExpert.TemplateVersion, dest.TemplateVersion)
+		assert.Equal(t, question.ID.String(), dest.TurnID)
+		assert.Contains(t, dest.SystemPrompt, "hiking")
+		assert.Equal(t, "Visit Torres del Paine", dest.Output)
+		assert.Equal(t, "rate limited", body.AgentSessions[1].Error)
+	})
+
+	t.Run("rejects other users", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/travel/conversations/"+chat.ID.String()+"?userId="+uuid.NewString(), nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("unknown conversation", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/travel/conversations/"+uuid.NewString()+"?userId="+userID.String(), nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestRecommendation_StreamsPipelineOffline(t *testing.T) {
+	client := llm.NewScriptedClient(
+		llm.ScriptRule{Name: "guard", SystemContains: "You screen the messages", Structured: domain.InjectionAssessment{Reason: domain.BlockNone}},
+		llm.ScriptRule{Name: "extract", SystemContains: "extrae los cambios", Structured: domain.ExtractedIntent{
+			Destinations: domain.ExtractedField{Op: domain.OpAdd, Values: []string{"Panama"}},
+			Preferences:  domain.ExtractedField{Op: domain.OpAdd, Values: []string{"cheap"}},
+			Interest:     domain.ExtractedField{Op: domain.OpAdd, Values: []string{"beaches"}},
+		}},
+		llm.ScriptRule{Name: "destination", SystemContains: "experto local en viajes", Reply: "Bocas del Toro"},
+		llm.ScriptRule{Name: "budget", SystemContains: "agente de viajes atento a los costos", Structured: domain.BudgetEstimate{
+			Destinations: []domain.DestinationEstimate{{
+				Destination: "Panama",
+				Currency:    domain.CurrencyUSD,
+				Nights:      4,
+				Travelers:   1,
+				Items: []domain.BudgetLineItem{
+					{Category: domain.CostFlights, Amount: 500},
+					{Category: domain.CostAccommodation, Amount: 250},
+					{Category: domain.CostFood, Amount: 150},
+				},
+			}},
+		}},
+		llm.ScriptRule{Name: "synthesis", SystemContains: "asesor de viajes sénior", Chunks: []string{"Bocas del Toro", " for ~$900 USD"}},
+	)
+	store := repository.NewMemoryStore()
+	service := application.NewChatService(application.NewAgentRunner(client), application.NewStructuredRunner(client))
+	orchestrator := application.NewMultiAgentOrchestrator(service, store, store, store, application.AgentModels{
+		domain.InformationExtractor: "gpt-4o",
+		domain.DestinationExpert:    "gpt-4",
+		domain.BudgetPlanner:        "gpt-4o",
+		domain.TripSynthesizer:      "gpt-4",
+	}, nil, nil, nil, nil)
+
+	app := fiber.New()
+	NewTravelHandler(orchestrator, application.NewConversationHistory(store, store)).RegisterRoutes(app)
+
+	body := `{"conversationId":"` + uuid.NewString() + `","userId":"` + uuid.NewString() + `",` +
+		`"message":{"role":"user","content":"Beaches in Panama on a budget"}}`
+	req, _ := http.NewRequest(http.MethodPost, "/travel/recommendation", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es-MX, en;q=0.8") // overrides the English message
+
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	runID := resp.Header.Get("X-Run-ID")
+	require.NotEmpty(t, runID)
+	assert.True(t, strings.HasPrefix(string(raw), "id: "+runID+":1\nevent: status\ndata: {"))
+
+	envelopes := parseEnvelopes(t, string(raw))
+	texts := map[string][]string{}
+	for i, e := range envelopes {
+		assert.Equal(t, EventEnvelopeVersion, e.Version)
+		assert.Equal(t, runID, e.RunID)
+		assert.Equal(t, uint64(i+1), e.Seq)
+		assert.False(t, e.Timestamp.IsZero())
+		texts[e.Type] = append(texts[e.Type], e.Text)
+	}
+
+	first := envelopes[0]
+	assert.Equal(t, "input_guard", first.Agent)
+	assert.Equal(t, "started", first.Phase)
+	assert.Equal(t, "Invoking LLM 0 (input guard)", first.Text)
+
+	assert.Equal(t, []string{"Bocas del Toro"}, texts["destination.delta"])
+	require.Len(t, texts["budget.delta"], 1)
+	assert.Contains(t, texts["budget.delta"][0], "Estimated Budget: ~$900 USD for 1 traveler, 4 nights")
+	assert.Equal(t, []string{"Bocas del Toro", " for ~$900 USD"}, texts["synthesis.delta"])
+	last := envelopes[len(envelopes)-1]
+	assert.Equal(t, "status", last.Type)
+	assert.Equal(t, "completed", last.Phase)
+	assert.Empty(t, texts["error"])
+}
+
+func TestRecommendation_ReportsAFailureOnce(t *testing.T) {
+	client := llm.NewScriptedClient(
+		llm.ScriptRule{Name: "guard", SystemContains: "You screen the messages", Structured: domain.InjectionAssessment{Reason: domain.BlockNone}},
+		llm.ScriptRule{Name: "extract", SystemContains: "extract the changes", Err: errors.New("upstream 503")},
+	)
+	store := repository.NewMemoryStore()
+	service := application.NewChatService(application.NewAgentRunner(client), application.NewStructuredRunner(client))
+	orchestrator := application.NewMultiAgentOrchestrator(service, store, store, store, nil, nil, nil, nil, nil)
+
+	app := fiber.New()
+	NewTravelHandler(orchestrator, application.NewConversationHistory(store, store)).RegisterRoutes(app)
+
+	body := `{"conversationId":"` + uuid.NewString() + `","userId":"` + uuid.NewString() + `",` +
+		`"message":{"role":"user","content":"Beaches in Panama on a budget"}}`
+	req, _ := http.NewRequest(http.MethodPost, "/travel/recommendation", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var failures []EventEnvelope
+	for _, e := range parseEnvelopes(t, string(raw)) {
+		if e.Type == "error" {
+			failures = append(failures, e)
+		}
+	}
+	require.Len(t, failures, 1)
+	assert.Equal(t, "information_extractor", failures[0].Agent)
+	assert.Equal(t, "agent_failed", failures[0].Code)
+}
+
+func TestRunOutcomes(t *testing.T) {
+	app := fiber.New()
+	NewTravelHandler(nil, nil).RegisterRoutes(app)
+	outcomes := func() map[string]int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/travel/runs/outcomes", nil))
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+		var counts map[string]int
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&counts))
+		return counts
+	}
+
+	before := outcomes()
+	recordOutcome(errors.New("upstream 503"))
+	assert.Equal(t, before[outcomeFailed]+1, outcomes()[outcomeFailed])
+}
+
+// parseEnvelopes decodes the data of every event of an SSE stream.
+func parseEnvelopes(t *testing.T, stream string) []EventEnvelope {
+	var envelopes []EventEnvelope
+	for _, line := range strings.Split(stream, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var e EventEnvelope
+		require.NoError(t, json.Unmarshal([]byte(data), &e), data)
+		envelopes = append(envelopes, e)
+	}
+	return envelopes
+}
+
+func TestRecommendation_RejectsInvalidBody(t *testing.T) {
+	app := fiber.New()
+	NewTravelHandler(nil, nil).RegisterRoutes(app)
+
+	for _, body := range []string{
+		`{"conversationId":"nope"}`,
+		`{"conversationId":"` + uuid.NewString() + `","userId":"` + uuid.NewString() + `",` +
+			`"message":{"role":"user","content":"Beaches in Panama"},"currency":"EURO"}`,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, "/travel/recommendation", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+}
@@ -1,6 +1,12 @@
 package chathttpadapter
 
-import "github.com/go-playground/validator/v10"
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
 
 type ChatRequestDTO struct {
 	ConversationID string `json:"conversationId" validate:"required,uuid4"`
@@ -9,6 +15,9 @@ type ChatRequestDTO struct {
 		Content string `json:"content" validate:"required"`
 	} `json:"message" validate:"required"`
 	UserID string `json:"userId" validate:"required,uuid4"`
+	// Currency is the ISO 4217 code to give prices in, overriding the one the
+	// conversation asked for.
+	Currency string `json:"currency,omitempty" validate:"omitempty,iso4217"`
 }
 
 type ValidationErrorResponse struct {
@@ -34,3 +43,110 @@ func (dto *ChatRequestDTO) Validate() []*ValidationErrorResponse {
 	}
 	return errors
 }
+
+type MessageDTO struct {
+	ID        string    `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type AgentSessionDTO struct {
+	ID              string            `json:"id"`
+	TurnID          string            `json:"turnId"`
+	Agent           string            `json:"agent"`
+	TemplateVersion string            `json:"templateVersion,omitempty"`
+	Inputs          map[string]string `json:"inputs"`
+	SystemPrompt    string            `json:"systemPrompt"`
+	Messages        []MessageDTO      `json:"messages"`
+	Output          string            `json:"output"`
+	Error           string            `json:"error,omitempty"`
+	StartedAt       time.Time         `json:"startedAt"`
+	FinishedAt      time.Time         `json:"finishedAt"`
+}
+
+type ConversationResponseDTO struct {
+	ConversationID string            `json:"conversationId"`
+	UserID         string            `json:"userId"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	Messages       []MessageDTO      `json:"messages"`
+	AgentSessions  []AgentSessionDTO `json:"agentSessions"`
+}
+
+func toMessageDTOs(messages []domain.Message) []MessageDTO {
+	dtos := make([]MessageDTO, 0, len(messages))
+	for _, m := range messages {
+		dtos = append(dtos, MessageDTO{
+			ID:        m.ID.String(),
+			Role:      m.Sender.String(),
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+		})
+	}
+	return dtos
+}
+
+func toConversationResponseDTO(view *application.ConversationView) ConversationResponseDTO {
+	sessions := make([]AgentSessionDTO, 0, len(view.Sessions))
+	for _, s := range view.Sessions {
+		dto := AgentSessionDTO{
+			ID:              s.ID.String(),
+			TurnID:          s.TurnID.String(),
+			Agent:           string(s.Agent),
+			TemplateVersion: s.TemplateVersion,
+			Inputs:          s.Inputs,
+			SystemPrompt:    s.SystemPrompt(),
+			Messages:        []MessageDTO{},
+			Output:          s.Output,
+			Error:           s.Error,
+			StartedAt:       s.StartedAt,
+			FinishedAt:      s.FinishedAt,
+		}
+		if s.Chat != nil {
+			dto.Messages = toMessageDTOs(s.Chat.Messages)
+		}
+		sessions = append(sessions, dto)
+	}
+
+	return ConversationResponseDTO{
+		ConversationID: view.Chat.ID.String(),
+		UserID:         view.Chat.UserID.String(),
+		CreatedAt:      view.Chat.CreatedAt,
+		Messages:       toMessageDTOs(view.Chat.Messages),
+		AgentSessions:  sessions,
+	}
+}
+
+// EventEnvelopeVersion is the version of the EventEnvelope format, raised on
+// every incompatible change.
+const EventEnvelopeVersion = 1
+
+// EventEnvelope is the JSON carried by the data line of every event of the
+// recommendation stream. Its JSON Schema is served at /travel/events/schema.
+type EventEnvelope struct {
+	Version   int       `json:"version" jsonschema:"description=Envelope format version"`
+	RunID     string    `json:"runId"`
+	Seq       uint64    `json:"seq" jsonschema:"description=Position of the event in its run; the first event is 1"`
+	Type      string    `json:"type" jsonschema:"enum=status,enum=tool,enum=degraded,enum=error,enum=clarification,enum=verification,enum=blocked,enum=destination.delta,enum=budget.delta,enum=synthesis.delta,enum=agent.delta"`
+	Agent     string    `json:"agent,omitempty" jsonschema:"description=Agent the event is about; absent for events about the whole run"`
+	Phase     string    `json:"phase,omitempty" jsonschema:"enum=started,enum=progress,enum=completed,enum=reused,enum=retrying,enum=failed,enum=paused"`
+	Timestamp time.Time `json:"timestamp"`
+	Text      string    `json:"text,omitempty" jsonschema:"description=Human-readable status; for delta events the next piece of the answer"`
+	Code      string    `json:"code,omitempty" jsonschema:"enum=storage_failed,enum=agent_failed,enum=agent_timeout,enum=interrupted,enum=internal"`
+	Payload   any       `json:"payload,omitempty" jsonschema:"description=Structured details: the degradation of a degraded event; the call of a tool event; the question of a clarification event; the grounding report of a verification event; the verdict of a blocked event; the budget plan of a budget.delta event"`
+}
+
+func toEventEnvelope(runID string, seq uint64, at time.Time, e application.Event) EventEnvelope {
+	return EventEnvelope{
+		Version:   EventEnvelopeVersion,
+		RunID:     runID,
+		Seq:       seq,
+		Type:      string(e.Type),
+		Agent:     string(e.Agent),
+		Phase:     string(e.Phase),
+		Timestamp: at.UTC(),
+		Text:      e.Text,
+		Code:      string(e.Code),
+		Payload:   e.Payload,
+	}
+}
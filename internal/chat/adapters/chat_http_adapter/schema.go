@@ -0,0 +1,33 @@
+package chathttpadapter
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/invopop/jsonschema"
+)
+
+// eventEnvelopeSchema is the published JSON Schema of EventEnvelope. It is
+// checked in so clients can generate types from it; a test keeps it in sync
+// with the Go type.
+//
+//go:embed event_envelope.schema.json
+var eventEnvelopeSchema []byte
+
+// reflectEventEnvelopeSchema derives the JSON Schema of EventEnvelope.
+func reflectEventEnvelopeSchema() ([]byte, error) {
+	// Clients must ignore fields they do not know: new optional fields are
+	// added without raising EventEnvelopeVersion.
+	reflector := jsonschema.Reflector{ExpandedStruct: true, AllowAdditionalProperties: true}
+	schema := reflector.Reflect(&EventEnvelope{})
+	schema.ID = "https://acai.travel/schemas/event-envelope.v1.json"
+	schema.Title = "Recommendation stream event"
+	schema.Description = "The data of every event of POST /travel/recommendation."
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func (h *TravelHandler) getEventSchema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "application/schema+json")
+	return c.Send(eventEnvelopeSchema)
+}
@@ -0,0 +1,74 @@
+package chathttpadapter
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite event_envelope.schema.json from EventEnvelope")
+
+func TestEventEnvelopeSchema_IsUpToDate(t *testing.T) {
+	generated, err := reflectEventEnvelopeSchema()
+	require.NoError(t, err)
+	generated = append(generated, '\n')
+
+	if *update {
+		require.NoError(t, os.WriteFile("event_envelope.schema.json", generated, 0o644))
+		return
+	}
+	assert.Equal(t, string(generated), string(eventEnvelopeSchema),
+		"EventEnvelope changed: run go test ./internal/chat/adapters/chat_http_adapter -run Schema -update")
+}
+
+func TestEventEnvelope(t *testing.T) {
+	envelope := toEventEnvelope("run-1", 7, time.Date(2025, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600)), application.Event{
+		Type:    application.EventDegraded,
+		Agent:   domain.BudgetPlanner,
+		Phase:   application.PhaseFailed,
+		Code:    application.CodeAgentTimeout,
+		Text:    "The budget planner did not answer.",
+		Payload: domain.Degradation{Agent: domain.BudgetPlanner, Policy: domain.PolicyContinue, Outcome: domain.OutcomeOmitted, Reason: "timeout"},
+	})
+
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"runId": "run-1",
+		"seq": 7,
+		"type": "degraded",
+		"agent": "budget_planner",
+		"phase": "failed",
+		"timestamp": "2025-01-01T11:00:00Z",
+		"text": "The budget planner did not answer.",
+		"code": "agent_timeout",
+		"payload": {"agent": "budget_planner", "policy": "continue", "outcome": "omitted", "reason": "timeout"}
+	}`, string(data))
+}
+
+func TestGetEventSchema(t *testing.T) {
+	app := fiber.New()
+	NewTravelHandler(nil, nil).RegisterRoutes(app)
+
+	req, _ := http.NewRequest(http.MethodGet, "/travel/events/schema", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/schema+json", resp.Header.Get("Content-Type"))
+
+	body, _ := io.ReadAll(resp.Body)
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(body, &schema))
+	assert.Equal(t, "Recommendation stream event", schema["title"])
+}
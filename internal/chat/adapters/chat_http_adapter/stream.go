@@ -0,0 +1,323 @@
+package chathttpadapter
+
+import (
+	"acai_travel/internal/chat/application"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// finishedRunTTL is how long the events of a finished run stay available for replay.
+	finishedRunTTL = 10 * time.Minute
+	// abandonAfter is how long a run keeps going with no client following it,
+	// which gives a dropped client time to reconnect before its LLM calls are
+	// cancelled.
+	abandonAfter = 15 * time.Second
+	// heartbeatInterval is how often an idle stream is written to, so a client
+	// that went away is noticed even while the agents are still thinking.
+	heartbeatInterval = 10 * time.Second
+)
+
+// errRunAbandoned is the cancellation cause of a run nobody followed for abandonAfter.
+var errRunAbandoned = errors.New("client disconnected")
+
+// Outcomes of a run, as logged and counted in the travel_runs expvar map,
+// served at GET /travel/runs/outcomes.
+const (
+	outcomeCompleted = "completed"
+	outcomeCancelled = "cancelled" // the client went away
+	outcomeTimedOut  = "timed_out"
+	outcomeFailed    = "failed"
+)
+
+var runOutcomes = expvar.NewMap("travel_runs")
+
+// recordOutcome classifies how a run ended and counts it.
+func recordOutcome(err error) string {
+	outcome := outcomeFailed
+	switch {
+	case err == nil:
+		outcome = outcomeCompleted
+	case errors.Is(err, errRunAbandoned), errors.Is(err, application.ErrStreamClosed):
+		outcome = outcomeCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		outcome = outcomeTimedOut
+	}
+	runOutcomes.Add(outcome, 1)
+	return outcome
+}
+
+// sseEvent is a single event emitted by a run. Seq is monotonic within the run
+// and starts at 1.
+type sseEvent struct {
+	Seq  uint64
+	Type string
+	Data string
+}
+
+// runStream buffers every event of one orchestrator run so clients that drop
+// their connection can reconnect with Last-Event-ID and replay what they missed.
+type runStream struct {
+	id           string
+	abandonAfter time.Duration
+	heartbeat    time.Duration
+	now          func() time.Time
+
+	mu          sync.Mutex
+	events      []sseEvent
+	done        bool
+	finishedAt  time.Time
+	changed     chan struct{} // closed and replaced whenever events or done change
+	subscribers int
+	abandonTmr  *time.Timer
+	abandoned   bool
+	failed      bool // an error event was published
+	cancel      context.CancelCauseFunc
+}
+
+func newRunStream() *runStream {
+	return &runStream{
+		id:           uuid.NewString(),
+		abandonAfter: abandonAfter,
+		heartbeat:    heartbeatInterval,
+		now:          time.Now,
+		changed:      make(chan struct{}),
+	}
+}
+
+// context derives the context the run's work is done under. It is cancelled
+// with errRunAbandoned once no client followed the run for abandonAfter.
+func (r *runStream) context(parent context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(parent)
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	return ctx
+}
+
+// subscribe registers a client following the run and stops any pending abandonment.
+func (r *runStream) subscribe() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers++
+	if r.abandonTmr != nil {
+		r.abandonTmr.Stop()
+		r.abandonTmr = nil
+	}
+}
+
+// unsubscribe removes a client. When the last one leaves an unfinished run, the
+// run is abandoned unless someone reconnects within abandonAfter.
+func (r *runStream) unsubscribe() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers--
+	if r.subscribers > 0 || r.done {
+		return
+	}
+	r.abandonTmr = time.AfterFunc(r.abandonAfter, r.abandon)
+}
+
+func (r *runStream) abandon() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.subscribers > 0 || r.done || r.abandoned {
+		return
+	}
+	r.abandoned = true
+	if r.cancel != nil {
+		r.cancel(errRunAbandoned)
+	}
+}
+
+// publish appends an event to the run, wrapped in its envelope. It is the
+// application.EventSink the orchestrator reports to.
+func (r *runStream) publish(e application.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done {
+		return fmt.Errorf("run %s already finished", r.id)
+	}
+	if r.abandoned {
+		return fmt.Errorf("run %s: %w", r.id, errRunAbandoned)
+	}
+
+	seq := uint64(len(r.events) + 1)
+	data, err := json.Marshal(toEventEnvelope(r.id, seq, r.now(), e))
+	if err != nil {
+		return fmt.Errorf("run %s: encode %s event: %w", r.id, e.Type, err)
+	}
+	r.events = append(r.events, sseEvent{Seq: seq, Type: string(e.Type), Data: string(data)})
+	r.failed = r.failed || e.Type == application.EventError
+	r.broadcast()
+	return nil
+}
+
+// reportedFailure reports whether the run already told its clients it failed.
+func (r *runStream) reportedFailure() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// finish marks the run as complete; subscribers drain the remaining events and stop.
+func (r *runStream) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done = true
+	r.finishedAt = time.Now()
+	if r.abandonTmr != nil {
+		r.abandonTmr.Stop()
+		r.abandonTmr = nil
+	}
+	r.broadcast()
+}
+
+// broadcast must be called with r.mu held.
+func (r *runStream) broadcast() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// since returns the events after lastSeq, whether the run is finished, and a
+// channel that is closed on the next change.
+func (r *runStream) since(lastSeq uint64) ([]sseEvent, bool, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []sseEvent
+	if lastSeq < uint64(len(r.events)) {
+		events = append(events, r.events[lastSeq:]...)
+	}
+	return events, r.done, r.changed
+}
+
+func (r *runStream) expired(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done && now.Sub(r.finishedAt) > finishedRunTTL
+}
+
+// eventID renders the SSE id of an event. The run ID is part of it so a
+// Last-Event-ID header alone identifies what to resume.
+func (r *runStream) eventID(seq uint64) string {
+	return r.id + ":" + strconv.FormatUint(seq, 10)
+}
+
+// parseEventID splits a Last-Event-ID value into run ID and sequence number.
+func parseEventID(id string) (string, uint64, error) {
+	runID, seq, ok := strings.Cut(id, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed event ID %q", id)
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed event ID %q: %w", id, err)
+	}
+	return runID, n, nil
+}
+
+// runRegistry tracks in-flight and recently finished runs.
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*runStream
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: make(map[string]*runStream)}
+}
+
+// start registers a new run, evicting finished runs past their TTL.
+func (reg *runRegistry) start() *runStream {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	now := time.Now()
+	for id, run := range reg.runs {
+		if run.expired(now) {
+			delete(reg.runs, id)
+		}
+	}
+
+	run := newRunStream()
+	reg.runs[run.id] = run
+	return run
+}
+
+func (reg *runRegistry) get(id string) (*runStream, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	run, ok := reg.runs[id]
+	if !ok || run.expired(time.Now()) {
+		return nil, false
+	}
+	return run, true
+}
+
+// writeSSE writes one event in text/event-stream format. Multi-line data is
+// split across several data: lines as the format requires.
+func writeSSE(w *bufio.Writer, id string, event sseEvent) error {
+	if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\n", id, event.Type); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(event.Data, "\n") {
+		if _, err := fmt.Fprintf(w, "data: %s\n", line); err != nil {
+			return err
+		}
+	}
+	if _, err := w.WriteString("\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// follow writes every event after lastSeq to w and keeps following the run
+// until it finishes or the client goes away, which shows up as a failed write.
+// Idle periods are filled with SSE comments so a departed client is noticed.
+func follow(w *bufio.Writer, run *runStream, lastSeq uint64) error {
+	run.subscribe()
+	defer run.unsubscribe()
+
+	heartbeat := time.NewTicker(run.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		events, done, changed := run.since(lastSeq)
+		for _, e := range events {
+			if err := writeSSE(w, run.eventID(e.Seq), e); err != nil {
+				return err
+			}
+			lastSeq = e.Seq
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+				return err
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
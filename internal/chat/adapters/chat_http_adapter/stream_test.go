@@ -0,0 +1,193 @@
+package chathttpadapter
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// status is a status event about the whole run.
+func status(text string) application.Event {
+	return application.Event{Type: application.EventStatus, Text: text}
+}
+
+var streamEpoch = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRunStream_ReplayAndFollow(t *testing.T) {
+	run := newRunStream()
+	run.now = func() time.Time { return streamEpoch }
+	require.NoError(t, run.publish(status("Invoking LLM 1 (extraction)")))
+	require.NoError(t, run.publish(status("Got response from LLM 1 (info extracted)")))
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	done := make(chan error)
+	go func() { done <- follow(w, run, 1) }()
+
+	require.NoError(t, run.publish(application.Event{Type: application.EventSynthesisDelta, Agent: domain.TripSynthesizer, Text: "line one\nline two"}))
+	run.finish()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("follow did not return after the run finished")
+	}
+
+	expected := "id: " + run.id + ":2\nevent: status\n" +
+		`data: {"version":1,"runId":"` + run.id + `","seq":2,"type":"status","timestamp":"2025-01-01T12:00:00Z","text":"Got response from LLM 1 (info extracted)"}` + "\n\n" +
+		"id: " + run.id + ":3\nevent: synthesis.delta\n" +
+		`data: {"version":1,"runId":"` + run.id + `","seq":3,"type":"synthesis.delta","agent":"trip_synthesizer","timestamp":"2025-01-01T12:00:00Z","text":"line one\nline two"}` + "\n\n"
+	assert.Equal(t, expected, buf.String())
+	assert.Error(t, run.publish(status("late")))
+}
+
+// brokenPipe fails every write, like the connection of a client that left.
+type brokenPipe struct{}
+
+func (brokenPipe) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestRunStream_AbandonedRunIsCancelled(t *testing.T) {
+	run := newRunStream()
+	run.abandonAfter = 10 * time.Millisecond
+	ctx := run.context(context.Background())
+	require.NoError(t, run.publish(status("Invoking LLM 1 (extraction)")))
+
+	assert.Error(t, follow(bufio.NewWriterSize(brokenPipe{}, 16), run, 0))
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, context.Cause(ctx), errRunAbandoned)
+	case <-time.After(time.Second):
+		t.Fatal("run was not cancelled after its client left")
+	}
+	assert.ErrorIs(t, run.publish(status("late")), errRunAbandoned)
+}
+
+func TestRunStream_ReconnectWithinGraceKeepsRunning(t *testing.T) {
+	run := newRunStream()
+	run.abandonAfter = 50 * time.Millisecond
+	ctx := run.context(context.Background())
+
+	run.subscribe()
+	run.unsubscribe()
+	run.subscribe() // the client reconnected
+	time.Sleep(2 * run.abandonAfter)
+
+	assert.NoError(t, ctx.Err())
+	assert.NoError(t, run.publish(status("still here")))
+	run.unsubscribe()
+	run.finish()
+	time.Sleep(2 * run.abandonAfter)
+	assert.NoError(t, ctx.Err(), "a finished run is not abandoned")
+}
+
+func TestFollow_Heartbeat(t *testing.T) {
+	run := newRunStream()
+	run.heartbeat = 5 * time.Millisecond
+
+	var buf safeBuffer
+	w := bufio.NewWriter(&buf)
+	done := make(chan error)
+	go func() { done <- follow(w, run, 0) }()
+
+	assert.Eventually(t, func() bool { return bytes.Contains(buf.Bytes(), []byte(": keep-alive\n\n")) }, time.Second, 5*time.Millisecond)
+	run.finish()
+	require.NoError(t, <-done)
+}
+
+// safeBuffer is a bytes.Buffer that can be read while follow writes to it.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestRecordOutcome(t *testing.T) {
+	assert.Equal(t, outcomeCompleted, recordOutcome(nil))
+	assert.Equal(t, outcomeCancelled, recordOutcome(fmt.Errorf("pipeline interrupted: %w", errRunAbandoned)))
+	assert.Equal(t, outcomeCancelled, recordOutcome(fmt.Errorf("%w: broken pipe", application.ErrStreamClosed)))
+	assert.Equal(t, outcomeTimedOut, recordOutcome(fmt.Errorf("pipeline interrupted: %w", context.DeadlineExceeded)))
+	assert.Equal(t, outcomeFailed, recordOutcome(errors.New("LLM 4 failed: boom")))
+	assert.Equal(t, "1", runOutcomes.Get(outcomeTimedOut).String())
+}
+
+func TestParseEventID(t *testing.T) {
+	runID, seq, err := parseEventID("abc:42")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", runID)
+	assert.Equal(t, uint64(42), seq)
+
+	_, _, err = parseEventID("abc")
+	assert.Error(t, err)
+	_, _, err = parseEventID("abc:x")
+	assert.Error(t, err)
+}
+
+func TestRecommendation_ResumeWithLastEventID(t *testing.T) {
+	handler := NewTravelHandler(nil, nil)
+	app := fiber.New()
+	handler.RegisterRoutes(app)
+
+	run := handler.runs.start()
+	require.NoError(t, run.publish(status("one")))
+	require.NoError(t, run.publish(status("two")))
+	require.NoError(t, run.publish(application.Event{Type: application.EventSynthesisDelta, Text: "three"}))
+	run.finish()
+
+	t.Run("POST replays events after Last-Event-ID", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/travel/recommendation", nil)
+		req.Header.Set("Last-Event-ID", run.eventID(1))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, run.id, resp.Header.Get("X-Run-ID"))
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.NotContains(t, string(body), `"text":"one"`)
+		assert.Contains(t, string(body), "id: "+run.eventID(2)+"\nevent: status\ndata: {")
+		assert.Contains(t, string(body), `"seq":2,"type":"status",`)
+		assert.Contains(t, string(body), "id: "+run.eventID(3)+"\nevent: synthesis.delta\ndata: {")
+		assert.Contains(t, string(body), `"text":"three"}`)
+	})
+
+	t.Run("GET events endpoint replays from the start", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/travel/recommendation/"+run.id+"/events", nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `"text":"one"`)
+	})
+
+	t.Run("unknown run is gone", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/travel/recommendation", nil)
+		req.Header.Set("Last-Event-ID", "missing:3")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+	})
+}
@@ -0,0 +1,151 @@
+package exchange
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Table is a domain.ExchangeRateProvider answering from a table of rates
+// against one base currency. A table loaded from a file can be refreshed while
+// it is in use, so rates can be updated without a redeploy and tests never
+// depend on the network.
+type Table struct {
+	path string // reloaded by Refresh; empty for a static table
+
+	mu    sync.RWMutex
+	rates map[domain.Currency]float64 // units of each currency one unit of the base buys
+	asOf  time.Time
+}
+
+// tableFile is the JSON layout of a rates file:
+//
+//	{"base": "USD", "asOf": "2026-10-01", "rates": {"EUR": 0.92, "MXN": 17.1}}
+type tableFile struct {
+	Base  string             `json:"base"`
+	AsOf  string             `json:"asOf"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// NewStaticTable returns a table of fixed rates against base.
+func NewStaticTable(base domain.Currency, asOf time.Time, rates map[domain.Currency]float64) (*Table, error) {
+	t := &Table{}
+	if err := t.set(base, asOf, rates); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Parse reads a static table from the JSON layout of a rates file.
+func Parse(data []byte) (*Table, error) {
+	t := &Table{}
+	if err := t.load(data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadFile reads a table from a rates file that Refresh reads again.
+func LoadFile(path string) (*Table, error) {
+	t := &Table{path: path}
+	if err := t.Refresh(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Refresh reads the table's file again. An unreadable or invalid file keeps
+// the current rates. Static tables have nothing to refresh.
+func (t *Table) Refresh() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return fmt.Errorf("exchange rates: %w", err)
+	}
+	return t.load(data)
+}
+
+// Watch refreshes the table every interval until ctx is done. Failed refreshes
+// are logged and the current rates are kept.
+func (t *Table) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Refresh(); err != nil {
+				log.Printf("%v; keeping the current rates", err)
+			}
+		}
+	}
+}
+
+// Rate returns the rate from one currency to another, crossing through the
+// base currency when neither is the base.
+func (t *Table) Rate(_ context.Context, from, to domain.Currency) (domain.ExchangeRate, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	fromRate, ok := t.rates[from]
+	if !ok {
+		return domain.ExchangeRate{}, fmt.Errorf("exchange rates: %w %s", domain.ErrUnknownCurrency, from)
+	}
+	toRate, ok := t.rates[to]
+	if !ok {
+		return domain.ExchangeRate{}, fmt.Errorf("exchange rates: %w %s", domain.ErrUnknownCurrency, to)
+	}
+	return domain.ExchangeRate{From: from, To: to, Rate: toRate / fromRate, AsOf: t.asOf}, nil
+}
+
+func (t *Table) load(data []byte) error {
+	var file tableFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("exchange rates: %w", err)
+	}
+	base, ok := domain.ParseCurrency(file.Base)
+	if !ok {
+		return fmt.Errorf("exchange rates: invalid base currency %q", file.Base)
+	}
+	asOf, err := time.Parse(time.DateOnly, file.AsOf)
+	if err != nil {
+		return fmt.Errorf("exchange rates: asOf: %w", err)
+	}
+	rates := make(map[domain.Currency]float64, len(file.Rates))
+	for code, rate := range file.Rates {
+		currency, ok := domain.ParseCurrency(code)
+		if !ok {
+			return fmt.Errorf("exchange rates: invalid currency %q", code)
+		}
+		rates[currency] = rate
+	}
+	return t.set(base, asOf, rates)
+}
+
+// set validates and swaps in a new table.
+func (t *Table) set(base domain.Currency, asOf time.Time, rates map[domain.Currency]float64) error {
+	table := make(map[domain.Currency]float64, len(rates)+1)
+	for currency, rate := range rates {
+		if rate <= 0 {
+			return fmt.Errorf("exchange rates: %s rate must be positive, got %v", currency, rate)
+		}
+		table[currency] = rate
+	}
+	if rate, ok := table[base]; ok && rate != 1 {
+		return errors.New("exchange rates: the base currency's rate must be 1")
+	}
+	table[base] = 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rates, t.asOf = table, asOf
+	return nil
+}
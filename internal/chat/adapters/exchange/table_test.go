@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable_Rate(t *testing.T) {
+	asOf := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	table, err := NewStaticTable(domain.CurrencyUSD, asOf, map[domain.Currency]float64{"EUR": 0.8, "MXN": 16})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rate, err := table.Rate(ctx, domain.CurrencyUSD, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ExchangeRate{From: domain.CurrencyUSD, To: "EUR", Rate: 0.8, AsOf: asOf}, rate)
+
+	rate, err = table.Rate(ctx, "EUR", "MXN")
+	require.NoError(t, err)
+	assert.InDelta(t, 20, rate.Rate, 1e-9, "currencies other than the base cross through it")
+
+	_, err = table.Rate(ctx, domain.CurrencyUSD, "ARS")
+	assert.ErrorIs(t, err, domain.ErrUnknownCurrency)
+
+	_, err = NewStaticTable(domain.CurrencyUSD, asOf, map[domain.Currency]float64{"EUR": 0})
+	assert.ErrorContains(t, err, "EUR rate must be positive")
+}
+
+func TestTable_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	write := func(t *testing.T, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	eur := func(t *testing.T, table *Table) float64 {
+		rate, err := table.Rate(context.Background(), domain.CurrencyUSD, "EUR")
+		require.NoError(t, err)
+		return rate.Rate
+	}
+
+	write(t, `{"base": "USD", "asOf": "2026-10-01", "rates": {"EUR": 0.92}}`)
+	table, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0.92, eur(t, table))
+
+	write(t, `{"base": "USD", "asOf": "2026-10-02", "rates": {"EUR": 0.9}}`)
+	require.NoError(t, table.Refresh())
+	assert.Equal(t, 0.9, eur(t, table))
+
+	write(t, `{"base": "USD", "asOf": "2026-10-03", "rates": {"eur!": 0.5}}`)
+	assert.ErrorContains(t, table.Refresh(), `invalid currency "eur!"`)
+	assert.Equal(t, 0.9, eur(t, table), "an invalid file keeps the current rates")
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
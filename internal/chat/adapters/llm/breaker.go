@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the provider while a model's
+// circuit is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerConfig configures WithCircuitBreaker.
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive retryable failures that open the circuit
+	OpenFor          time.Duration // how long calls are rejected before a trial call is let through
+	Now              func() time.Time
+}
+
+// DefaultBreakerConfig opens a model's circuit after 5 consecutive failures and
+// tries again after 30 seconds.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{FailureThreshold: 5, OpenFor: 30 * time.Second}
+}
+
+// WithCircuitBreaker stops calling a model that keeps failing, so a provider
+// outage fails runs fast instead of piling up slow retries. Each model has its
+// own circuit. Only failures Classify deems retryable count, since a bad
+// request says nothing about the provider's health.
+func WithCircuitBreaker(cfg BreakerConfig) Middleware {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = DefaultBreakerConfig().FailureThreshold
+	}
+	if cfg.OpenFor <= 0 {
+		cfg.OpenFor = DefaultBreakerConfig().OpenFor
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return func(inner domain.LLMClient) domain.LLMClient {
+		return &breakerClient{inner: inner, cfg: cfg, circuits: make(map[string]*circuit)}
+	}
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+type breakerClient struct {
+	inner domain.LLMClient
+	cfg   BreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// allow reports whether a call to model may go ahead. Once the open period is
+// over, a single trial call is let through while the others keep failing fast.
+func (b *breakerClient) allow(model string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[model]
+	if !ok {
+		return nil
+	}
+	switch c.state {
+	case circuitOpen:
+		if b.cfg.Now().Sub(c.openedAt) < b.cfg.OpenFor {
+			return fmt.Errorf("%w for model %s", ErrCircuitOpen, model)
+		}
+		c.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		return fmt.Errorf("%w for model %s (trial call in progress)", ErrCircuitOpen, model)
+	}
+	return nil
+}
+
+// record updates model's circuit with the outcome of a call that was allowed
+// with ctx.
+func (b *breakerClient) record(ctx context.Context, model string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[model]
+	if !ok {
+		c = &circuit{}
+		b.circuits[model] = c
+	}
+
+	switch {
+	case err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled)):
+		// The caller gave up, which says nothing about the provider, unlike a
+		// request that timed out while the caller still waited. A cancelled
+		// trial call lets the next call try again.
+		if c.state == circuitHalfOpen {
+			c.state = circuitOpen
+			c.openedAt = b.cfg.Now().Add(-b.cfg.OpenFor)
+		}
+		return
+	case !Classify(err).Retryable:
+		// Success, or the provider answered and rejected the request.
+		c.state = circuitClosed
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= b.cfg.FailureThreshold {
+		if c.state != circuitOpen {
+			log.Printf("llm: circuit for model %s opened after %d consecutive failures: %v", model, c.failures, err)
+		}
+		c.state = circuitOpen
+		c.openedAt = b.cfg.Now()
+	}
+}
+
+func (b *breakerClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	if err := b.allow(model); err != nil {
+		return domain.Message{}, err
+	}
+	resp, err := b.inner.Chat(ctx, messages, model)
+	b.record(ctx, model, err)
+	return resp, err
+}
+
+func (b *breakerClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	if err := b.allow(model); err != nil {
+		return err
+	}
+	err := b.inner.StreamChat(ctx, messages, streamFn, model)
+	b.record(ctx, model, err)
+	return err
+}
+
+func (b *breakerClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	if err := b.allow(model); err != nil {
+		return nil, err
+	}
+	resp, err := b.inner.StructuredOutput(ctx, messages, model, schema)
+	b.record(ctx, model, err)
+	return resp, err
+}
+
+func (b *breakerClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	if err := b.allow(model); err != nil {
+		return domain.Message{}, err
+	}
+	resp, err := b.inner.ChatWithTools(ctx, messages, model, toolbox)
+	b.record(ctx, model, err)
+	return resp, err
+}
@@ -0,0 +1,308 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Interaction is one recorded request/response exchange with a provider.
+type Interaction struct {
+	Key        string            `json:"key"`
+	Kind       string            `json:"kind"`
+	Model      string            `json:"model"`
+	Messages   []CassetteMessage `json:"messages"`
+	Schema     json.RawMessage   `json:"schema,omitempty"`
+	Reply      string            `json:"reply,omitempty"`
+	Chunks     []string          `json:"chunks,omitempty"`
+	Structured json.RawMessage   `json:"structured,omitempty"`
+	ToolCalls  []domain.ToolCall `json:"toolCalls,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type CassetteMessage struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
+}
+
+// Cassette is a set of recorded interactions that can be saved to disk and
+// replayed by a ReplayClient.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("cassette: decode %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: encode: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// interactionKey identifies a request independently of message IDs and
+// timestamps, so a replayed run matches the recorded one even though every
+// chat gets fresh UUIDs.
+func interactionKey(kind, model string, messages []domain.Message, schema any) (string, []CassetteMessage, json.RawMessage, error) {
+	recorded := make([]CassetteMessage, 0, len(messages))
+	for _, m := range messages {
+		recorded = append(recorded, CassetteMessage{Sender: m.Sender.String(), Content: m.Content})
+	}
+
+	var rawSchema json.RawMessage
+	if schema != nil {
+		var err error
+		rawSchema, err = json.Marshal(schema)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("cassette: encode schema: %w", err)
+		}
+	}
+
+	body, err := json.Marshal(struct {
+		Kind     string
+		Model    string
+		Messages []CassetteMessage
+		Schema   json.RawMessage
+	}{kind, model, recorded, rawSchema})
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("cassette: encode request: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), recorded, rawSchema, nil
+}
+
+// RecordingClient forwards every call to another client and records the
+// exchange, including each streamed chunk, into a Cassette.
+type RecordingClient struct {
+	inner domain.LLMClient
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func NewRecordingClient(inner domain.LLMClient) *RecordingClient {
+	return &RecordingClient{inner: inner}
+}
+
+// Cassette returns a copy of everything recorded so far.
+func (r *RecordingClient) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+func (r *RecordingClient) record(in Interaction, err error) {
+	if err != nil {
+		in.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+}
+
+func (r *RecordingClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	key, recorded, _, err := interactionKey("chat", model, messages, nil)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	resp, err := r.inner.Chat(ctx, messages, model)
+	r.record(Interaction{Key: key, Kind: "chat", Model: model, Messages: recorded, Reply: resp.Content}, err)
+	return resp, err
+}
+
+func (r *RecordingClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	key, recorded, _, err := interactionKey("stream", model, messages, nil)
+	if err != nil {
+		return err
+	}
+
+	var chunks []string
+	err = r.inner.StreamChat(ctx, messages, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return streamFn(chunk)
+	}, model)
+	r.record(Interaction{Key: key, Kind: "stream", Model: model, Messages: recorded, Chunks: chunks}, err)
+	return err
+}
+
+func (r *RecordingClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	key, recorded, rawSchema, err := interactionKey("structured", model, messages, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.inner.StructuredOutput(ctx, messages, model, schema)
+	r.record(Interaction{Key: key, Kind: "structured", Model: model, Messages: recorded, Schema: rawSchema, Structured: result}, err)
+	return result, err
+}
+
+// ChatWithTools records the tool calls made during the exchange, with their
+// results, alongside the final answer.
+func (r *RecordingClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	key, recorded, _, err := interactionKey("tools", model, messages, toolDefinitions(toolbox))
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	var mu sync.Mutex
+	var calls []domain.ToolCall
+	var chunks []string
+	wrapped := domain.Toolbox{Observe: toolbox.Observe}
+	if toolbox.Stream != nil {
+		wrapped.Stream = func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return toolbox.Stream(chunk)
+		}
+	}
+	for _, tool := range toolbox.Tools {
+		handler := tool.Handler
+		name := tool.Name
+		tool.Handler = func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			result, err := handler(ctx, arguments)
+			call := domain.ToolCall{Name: name, Arguments: arguments, Result: result}
+			if err != nil {
+				call.Error = err.Error()
+			}
+			mu.Lock()
+			calls = append(calls, call)
+			mu.Unlock()
+			return result, err
+		}
+		wrapped.Tools = append(wrapped.Tools, tool)
+	}
+
+	resp, err := r.inner.ChatWithTools(ctx, messages, model, wrapped)
+	r.record(Interaction{Key: key, Kind: "tools", Model: model, Messages: recorded, Reply: resp.Content, Chunks: chunks, ToolCalls: calls}, err)
+	return resp, err
+}
+
+// toolDefinitions is the part of a toolbox the model sees, used to tell apart
+// requests that offer different tools.
+func toolDefinitions(toolbox domain.Toolbox) any {
+	type definition struct {
+		Name        string
+		Description string
+		Parameters  any
+	}
+	definitions := make([]definition, 0, len(toolbox.Tools))
+	for _, tool := range toolbox.Tools {
+		definitions = append(definitions, definition{tool.Name, tool.Description, tool.Parameters})
+	}
+	return definitions
+}
+
+// ErrNoInteraction is returned by a ReplayClient for a request that was never recorded.
+var ErrNoInteraction = errors.New("cassette: no recorded interaction for request")
+
+// ReplayClient answers from a Cassette. Requests are matched by content, so
+// concurrent agents may replay in any order; identical requests are answered
+// in the order they were recorded.
+type ReplayClient struct {
+	mu      sync.Mutex
+	pending map[string][]Interaction
+}
+
+func NewReplayClient(cassette *Cassette) *ReplayClient {
+	pending := make(map[string][]Interaction)
+	for _, in := range cassette.Interactions {
+		pending[in.Key] = append(pending[in.Key], in)
+	}
+	return &ReplayClient{pending: pending}
+}
+
+func (r *ReplayClient) next(kind, model string, messages []domain.Message, schema any) (Interaction, error) {
+	key, _, _, err := interactionKey(kind, model, messages, schema)
+	if err != nil {
+		return Interaction{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queue := r.pending[key]
+	if len(queue) == 0 {
+		return Interaction{}, fmt.Errorf("%w (%s, model %s, system prompt %q)", ErrNoInteraction, kind, model, truncate(systemPrompt(messages), 80))
+	}
+	r.pending[key] = queue[1:]
+
+	in := queue[0]
+	if in.Error != "" {
+		return in, errors.New(in.Error)
+	}
+	return in, nil
+}
+
+func (r *ReplayClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	in, err := r.next("chat", model, messages, nil)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	return domain.NewAIMessage(messages[0].ChatID, in.Reply), nil
+}
+
+func (r *ReplayClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	in, err := r.next("stream", model, messages, nil)
+	// Chunks delivered before a recorded failure are replayed too.
+	for _, chunk := range in.Chunks {
+		if err := streamFn(chunk); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *ReplayClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	in, err := r.next("structured", model, messages, schema)
+	if err != nil {
+		return nil, err
+	}
+	return in.Structured, nil
+}
+
+// ChatWithTools replays the recorded tool calls to the toolbox's observer
+// without running the handlers, then returns the recorded answer, streaming it
+// when the toolbox asks for it.
+func (r *ReplayClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	in, err := r.next("tools", model, messages, toolDefinitions(toolbox))
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if toolbox.Observe != nil {
+		for _, call := range in.ToolCalls {
+			if tool, ok := toolbox.Find(call.Name); ok {
+				toolbox.Observe(tool, call)
+			}
+		}
+	}
+	if toolbox.Stream != nil {
+		chunks := in.Chunks
+		if len(chunks) == 0 {
+			chunks = []string{in.Reply}
+		}
+		for _, chunk := range chunks {
+			if err := toolbox.Stream(chunk); err != nil {
+				return domain.Message{}, err
+			}
+		}
+	}
+	return domain.NewAIMessage(messages[0].ChatID, in.Reply), nil
+}
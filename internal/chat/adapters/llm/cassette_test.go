@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	scripted := NewScriptedClient(
+		ScriptRule{Name: "extract", SystemContains: "extract", Structured: map[string]string{"Destinations": "Peru"}},
+		ScriptRule{Name: "fail", LastUserContains: "boom", Err: errors.New("upstream 503")},
+		ScriptRule{Name: "story", Reply: "Once upon a time", Chunks: []string{"Once", " upon", " a ", "time"}},
+	)
+	recorder := NewRecordingClient(scripted)
+
+	chatID := uuid.New()
+	system := domain.NewSystemMessage(chatID, "extract the trip")
+	user := domain.NewUserMessage(chatID, "Peru please")
+	story := domain.NewUserMessage(chatID, "tell me a story")
+
+	structured, err := recorder.StructuredOutput(ctx, []domain.Message{system, user}, "gpt-4o", map[string]any{"type": "object"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Destinations":"Peru"}`, string(structured))
+
+	var recordedChunks []string
+	require.NoError(t, recorder.StreamChat(ctx, []domain.Message{story}, func(s string) error {
+		recordedChunks = append(recordedChunks, s)
+		return nil
+	}, "gpt-4"))
+
+	reply, err := recorder.Chat(ctx, []domain.Message{story}, "gpt-4")
+	require.NoError(t, err)
+
+	_, err = recorder.Chat(ctx, []domain.Message{domain.NewUserMessage(chatID, "boom")}, "gpt-4")
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, recorder.Cassette().Save(path))
+	cassette, err := LoadCassette(path)
+	require.NoError(t, err)
+	replay := NewReplayClient(cassette)
+
+	// Fresh IDs on replay, as a new run would have.
+	otherChat := uuid.New()
+	replayedReply, err := replay.Chat(ctx, []domain.Message{domain.NewUserMessage(otherChat, "tell me a story")}, "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, reply.Content, replayedReply.Content)
+	assert.Equal(t, otherChat, replayedReply.ChatID)
+
+	var replayedChunks []string
+	require.NoError(t, replay.StreamChat(ctx, []domain.Message{domain.NewUserMessage(otherChat, "tell me a story")}, func(s string) error {
+		replayedChunks = append(replayedChunks, s)
+		return nil
+	}, "gpt-4"))
+	assert.Equal(t, recordedChunks, replayedChunks)
+
+	replayedStructured, err := replay.StructuredOutput(ctx, []domain.Message{
+		domain.NewSystemMessage(otherChat, "extract the trip"),
+		domain.NewUserMessage(otherChat, "Peru please"),
+	}, "gpt-4o", map[string]any{"type": "object"})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(structured), string(replayedStructured))
+
+	_, err = replay.Chat(ctx, []domain.Message{domain.NewUserMessage(otherChat, "boom")}, "gpt-4")
+	assert.EqualError(t, err, "upstream 503")
+
+	_, err = replay.Chat(ctx, []domain.Message{domain.NewUserMessage(otherChat, "never recorded")}, "gpt-4")
+	assert.ErrorIs(t, err, ErrNoInteraction)
+
+	_, err = replay.Chat(ctx, []domain.Message{domain.NewUserMessage(otherChat, "tell me a story")}, "gpt-4")
+	assert.ErrorIs(t, err, ErrNoInteraction, "each recorded interaction is replayed once")
+}
+
+func TestScriptedClient_NoMatchingRule(t *testing.T) {
+	client := NewScriptedClient(ScriptRule{SystemContains: "budget", Reply: "cheap"})
+	_, err := client.Chat(context.Background(), []domain.Message{domain.NewUserMessage(uuid.New(), "hi")}, "gpt-4")
+	assert.ErrorContains(t, err, "no rule matches chat call")
+	assert.Empty(t, client.Calls())
+}
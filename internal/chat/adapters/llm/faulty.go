@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Fault is a failure injected into one call by a FaultyClient.
+type Fault struct {
+	Err error
+	// AfterChunks lets a StreamChat call deliver that many chunks from the
+	// inner client before failing. Other calls fail before reaching it.
+	AfterChunks int
+}
+
+// FaultyClient is a domain.LLMClient for tests that fails calls on purpose. The
+// n-th call gets the n-th fault; a nil Err, or running out of faults, lets the
+// call through to the inner client.
+type FaultyClient struct {
+	inner domain.LLMClient
+
+	mu     sync.Mutex
+	faults []Fault
+	calls  int
+}
+
+func NewFaultyClient(inner domain.LLMClient, faults ...Fault) *FaultyClient {
+	return &FaultyClient{inner: inner, faults: faults}
+}
+
+// Calls returns how many calls were received, failed or not.
+func (f *FaultyClient) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *FaultyClient) next() Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls > len(f.faults) {
+		return Fault{}
+	}
+	return f.faults[f.calls-1]
+}
+
+func (f *FaultyClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	if fault := f.next(); fault.Err != nil {
+		return domain.Message{}, fault.Err
+	}
+	return f.inner.Chat(ctx, messages, model)
+}
+
+func (f *FaultyClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	if fault := f.next(); fault.Err != nil {
+		return nil, fault.Err
+	}
+	return f.inner.StructuredOutput(ctx, messages, model, schema)
+}
+
+func (f *FaultyClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	if fault := f.next(); fault.Err != nil {
+		return domain.Message{}, fault.Err
+	}
+	return f.inner.ChatWithTools(ctx, messages, model, toolbox)
+}
+
+func (f *FaultyClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	fault := f.next()
+	if fault.Err == nil {
+		return f.inner.StreamChat(ctx, messages, streamFn, model)
+	}
+	if fault.AfterChunks == 0 {
+		return fault.Err
+	}
+
+	delivered := 0
+	err := f.inner.StreamChat(ctx, messages, func(chunk string) error {
+		if delivered == fault.AfterChunks {
+			return errInjected
+		}
+		delivered++
+		return streamFn(chunk)
+	}, model)
+	if err == errInjected || delivered == fault.AfterChunks {
+		return fault.Err
+	}
+	return err
+}
+
+// errInjected stops the inner stream once a fault's chunks were delivered.
+var errInjected = errors.New("fault injected")
@@ -2,7 +2,6 @@ package llm
 
 import (
 	"acai_travel/internal/chat/domain"
-	"fmt"
 	"github.com/invopop/jsonschema"
 	"github.com/openai/openai-go"
 )
@@ -22,28 +21,19 @@ func convertToOpenAIMessages(messages []domain.Message) []openai.ChatCompletionM
 	return converted
 }
 
-func mapModel(model string) (string, error) {
-	switch model {
-	case "gpt-4":
-		return openai.ChatModelGPT4, nil
-	case "gpt-4o":
-		return openai.ChatModelGPT4o, nil
-	case "gpt-3.5":
-		return openai.ChatModelGPT3_5Turbo, nil
-
-	default:
-		return "", fmt.Errorf("unsupported OpenAI model: %s", model)
-	}
+func GenerateSchema[T any]() interface{} {
+	var v T
+	return schemaOf(v)
 }
 
-func GenerateSchema[T any]() interface{} {
+// schemaOf reflects the schema of v's type; a pointer stands for the type it
+// points to.
+func schemaOf(v any) *jsonschema.Schema {
 	// Structured Outputs uses a subset of JSON schema
 	// These flags are necessary to comply with the subset
 	reflector := jsonschema.Reflector{
 		AllowAdditionalProperties: false,
 		DoNotReference:            true,
 	}
-	var v T
-	schema := reflector.Reflect(v)
-	return schema
+	return reflector.Reflect(v)
 }
@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// Middleware wraps an LLM client with extra behavior, such as retries, while
+// keeping the domain.LLMClient interface so middlewares compose.
+type Middleware func(domain.LLMClient) domain.LLMClient
+
+// Chain wraps client with the middlewares. The first middleware is the
+// outermost, so Chain(c, WithRetry(p), WithCircuitBreaker(b)) retries calls
+// that the breaker lets through.
+func Chain(client domain.LLMClient, middlewares ...Middleware) domain.LLMClient {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		client = middlewares[i](client)
+	}
+	return client
+}
+
+// ProviderError is a failed provider call described independently of the SDK
+// that made it. Adapters and fakes return it so failures can be classified.
+type ProviderError struct {
+	StatusCode int           // HTTP status, 0 when the request never got an answer
+	RetryAfter time.Duration // from the Retry-After header, if any
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("provider error: %v", e.Err)
+	}
+	return fmt.Sprintf("provider error (HTTP %d): %v", e.StatusCode, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Failure is how a failed call is classified.
+type Failure struct {
+	Retryable  bool          // the same request may succeed if sent again
+	RetryAfter time.Duration // how long the provider asked us to wait, if it did
+}
+
+// Classify decides whether a failed call is worth retrying. Rate limits,
+// timeouts, server errors and network failures are; bad requests,
+// authentication failures and cancellations are not. A deadline is taken for
+// a provider timeout, such as the per-request one of OPENAI_TIMEOUT, so callers
+// check their own context first: once it is done, nothing is worth retrying.
+func Classify(err error) Failure {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return Failure{}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Failure{Retryable: true}
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return Failure{}
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		if providerErr.StatusCode == 0 {
+			return Failure{Retryable: true, RetryAfter: providerErr.RetryAfter}
+		}
+		return Failure{Retryable: retryableStatus(providerErr.StatusCode), RetryAfter: providerErr.RetryAfter}
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		var retryAfter time.Duration
+		if apiErr.Response != nil {
+			retryAfter = parseRetryAfter(apiErr.Response.Header, time.Now())
+		}
+		return Failure{Retryable: retryableStatus(apiErr.StatusCode), RetryAfter: retryAfter}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Failure{Retryable: true}
+	}
+	return Failure{}
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= 500
+}
+
+// parseRetryAfter reads the Retry-After header in either of its forms, seconds
+// or an HTTP date, plus OpenAI's retry-after-ms.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
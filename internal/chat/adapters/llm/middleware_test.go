@@ -0,0 +1,350 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	rateLimited = &ProviderError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second, Err: errors.New("slow down")}
+	unavailable = &ProviderError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("overloaded")}
+	badRequest  = &ProviderError{StatusCode: http.StatusBadRequest, Err: errors.New("invalid model")}
+)
+
+// testRetryPolicy records the waits instead of sleeping and jitters to half of
+// the backoff ceiling.
+func testRetryPolicy(waits *[]time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+		Sleep: func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			return nil
+		},
+		Rand: func() float64 { return 0.5 },
+	}
+}
+
+func userMessages(content string) []domain.Message {
+	return []domain.Message{domain.NewUserMessage(uuid.New(), content)}
+}
+
+func TestClassify(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	apiErr := &openai.Error{StatusCode: http.StatusTooManyRequests, Response: &http.Response{Header: header}}
+
+	assert.Equal(t, Failure{Retryable: true, RetryAfter: 7 * time.Second}, Classify(apiErr))
+	assert.Equal(t, Failure{Retryable: true}, Classify(&openai.Error{StatusCode: http.StatusBadGateway}))
+	assert.Equal(t, Failure{}, Classify(&openai.Error{StatusCode: http.StatusUnauthorized}))
+	assert.Equal(t, Failure{Retryable: true, RetryAfter: 3 * time.Second}, Classify(rateLimited))
+	assert.Equal(t, Failure{}, Classify(badRequest))
+	assert.Equal(t, Failure{}, Classify(context.Canceled))
+	assert.Equal(t, Failure{Retryable: true}, Classify(fmt.Errorf("request: %w", context.DeadlineExceeded)), "a provider timeout")
+	assert.Equal(t, Failure{}, Classify(ErrCircuitOpen))
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	dated := http.Header{}
+	dated.Set("Retry-After", now.Add(90*time.Second).Format(http.TimeFormat))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(dated, now))
+	ms := http.Header{}
+	ms.Set("Retry-After-Ms", "250")
+	assert.Equal(t, 250*time.Millisecond, parseRetryAfter(ms, now))
+}
+
+func TestWithRetry_Chat(t *testing.T) {
+	scripted := NewScriptedClient(ScriptRule{Name: "ok", Reply: "Lima"})
+
+	t.Run("retries with backoff and honors Retry-After", func(t *testing.T) {
+		var waits []time.Duration
+		faulty := NewFaultyClient(scripted, Fault{Err: unavailable}, Fault{Err: rateLimited})
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+
+		resp, err := client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+		require.NoError(t, err)
+		assert.Equal(t, "Lima", resp.Content)
+		assert.Equal(t, 3, faulty.Calls())
+		assert.Equal(t, []time.Duration{50 * time.Millisecond, time.Second}, waits, "jittered backoff, then Retry-After capped at MaxDelay")
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		var waits []time.Duration
+		faulty := NewFaultyClient(scripted, Fault{Err: unavailable}, Fault{Err: unavailable}, Fault{Err: unavailable})
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+
+		_, err := client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+		assert.ErrorIs(t, err, unavailable)
+		assert.Equal(t, 3, faulty.Calls())
+		assert.Equal(t, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}, waits)
+	})
+
+	t.Run("does not retry non-retryable errors", func(t *testing.T) {
+		var waits []time.Duration
+		faulty := NewFaultyClient(scripted, Fault{Err: badRequest})
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+
+		_, err := client.StructuredOutput(context.Background(), userMessages("where?"), "gpt-4", map[string]any{})
+		assert.ErrorIs(t, err, badRequest)
+		assert.Equal(t, 1, faulty.Calls())
+		assert.Empty(t, waits)
+	})
+}
+
+func TestWithRetry_RetriesProviderTimeouts(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	var waits []time.Duration
+	client := Chain(
+		NewOpenAIClient("test-key", WithBaseURL(hanging.URL), WithRequestTimeout(20*time.Millisecond), WithMaxRetries(0)),
+		WithRetry(testRetryPolicy(&waits)),
+	)
+
+	_, err := client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(3), hits.Load(), "each attempt timed out while the caller was still waiting")
+
+	t.Run("not once the caller's deadline passed", func(t *testing.T) {
+		hits.Store(0)
+		client := Chain(NewOpenAIClient("test-key", WithBaseURL(hanging.URL), WithMaxRetries(0)), WithRetry(testRetryPolicy(&waits)))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Chat(ctx, userMessages("where?"), "gpt-4")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+}
+
+func TestWithRetry_ChatWithToolsDoesNotRepeatTools(t *testing.T) {
+	var runs int
+	toolbox := domain.Toolbox{Tools: []domain.Tool{{
+		Name: "book_hostel",
+		Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+			runs++
+			return "booked", nil
+		},
+	}}}
+	inner := &toolThenFail{}
+
+	var waits []time.Duration
+	client := Chain(inner, WithRetry(testRetryPolicy(&waits)))
+	_, err := client.ChatWithTools(context.Background(), userMessages("book it"), "gpt-4", toolbox)
+
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, 1, runs)
+	assert.Empty(t, waits)
+}
+
+// toolThenFail runs the first tool and then fails like a provider outage.
+type toolThenFail struct{ ScriptedClient }
+
+func (c *toolThenFail) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	runToolCalls(ctx, toolbox, []domain.ToolCall{{Name: toolbox.Tools[0].Name}})
+	return domain.Message{}, unavailable
+}
+
+func TestWithRetry_ChatWithToolsDoesNotRepeatStreamedAnswers(t *testing.T) {
+	var chunks []string
+	toolbox := domain.Toolbox{Stream: func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}}
+	inner := &streamThenFail{}
+
+	var waits []time.Duration
+	client := Chain(inner, WithRetry(testRetryPolicy(&waits)))
+	_, err := client.ChatWithTools(context.Background(), userMessages("plan it"), "gpt-4", toolbox)
+
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, []string{"Day 1: "}, chunks)
+	assert.Empty(t, waits)
+}
+
+// streamThenFail streams the start of an answer and then fails like a provider outage.
+type streamThenFail struct{ ScriptedClient }
+
+func (c *streamThenFail) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	_ = toolbox.Stream("Day 1: ")
+	return domain.Message{}, unavailable
+}
+
+func TestWithRetry_StreamChat(t *testing.T) {
+	scripted := NewScriptedClient(ScriptRule{Name: "story", Chunks: []string{"Once ", "upon ", "a ", "time"}})
+	collect := func(out *[]string) func(string) error {
+		return func(chunk string) error {
+			*out = append(*out, chunk)
+			return nil
+		}
+	}
+
+	t.Run("retries a stream that failed before any output", func(t *testing.T) {
+		var waits, chunks = []time.Duration{}, []string{}
+		faulty := NewFaultyClient(scripted, Fault{Err: unavailable})
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+
+		require.NoError(t, client.StreamChat(context.Background(), userMessages("story"), collect(&chunks), "gpt-4"))
+		assert.Equal(t, "Once upon a time", strings.Join(chunks, ""))
+		assert.Equal(t, 2, faulty.Calls())
+	})
+
+	t.Run("does not retry after partial output by default", func(t *testing.T) {
+		var waits, chunks = []time.Duration{}, []string{}
+		faulty := NewFaultyClient(scripted, Fault{Err: unavailable, AfterChunks: 2})
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+
+		err := client.StreamChat(context.Background(), userMessages("story"), collect(&chunks), "gpt-4")
+		assert.ErrorIs(t, err, ErrStreamInterrupted)
+		assert.ErrorIs(t, err, unavailable)
+		assert.Equal(t, []string{"Once ", "upon "}, chunks)
+		assert.Equal(t, 1, faulty.Calls())
+	})
+
+	t.Run("resumes after partial output without repeating it", func(t *testing.T) {
+		var waits, chunks = []time.Duration{}, []string{}
+		faulty := NewFaultyClient(scripted, Fault{Err: unavailable, AfterChunks: 2})
+		policy := testRetryPolicy(&waits)
+		policy.ResumeStreams = true
+		client := Chain(faulty, WithRetry(policy))
+
+		require.NoError(t, client.StreamChat(context.Background(), userMessages("story"), collect(&chunks), "gpt-4"))
+		assert.Equal(t, []string{"Once ", "upon ", "a ", "time"}, chunks)
+		assert.Equal(t, 2, faulty.Calls())
+	})
+
+	t.Run("fails when the resumed stream diverges", func(t *testing.T) {
+		var waits, chunks = []time.Duration{}, []string{}
+		inner := &divergingStream{answers: [][]string{{"Once ", "upon "}, {"Long ", "ago"}}}
+		policy := testRetryPolicy(&waits)
+		policy.ResumeStreams = true
+		client := Chain(inner, WithRetry(policy))
+
+		err := client.StreamChat(context.Background(), userMessages("story"), collect(&chunks), "gpt-4")
+		assert.ErrorIs(t, err, ErrStreamDiverged)
+		assert.Equal(t, []string{"Once ", "upon "}, chunks)
+	})
+
+	t.Run("does not retry errors from the consumer", func(t *testing.T) {
+		var waits []time.Duration
+		faulty := NewFaultyClient(scripted)
+		client := Chain(faulty, WithRetry(testRetryPolicy(&waits)))
+		stop := &ProviderError{Err: errors.New("client went away")}
+
+		err := client.StreamChat(context.Background(), userMessages("story"), func(string) error { return stop }, "gpt-4")
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, faulty.Calls())
+	})
+}
+
+// divergingStream streams a different answer on every call and fails all but
+// the last one.
+type divergingStream struct {
+	ScriptedClient
+	answers [][]string
+	calls   int
+}
+
+func (d *divergingStream) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	answer := d.answers[d.calls]
+	d.calls++
+	for _, chunk := range answer {
+		if err := streamFn(chunk); err != nil {
+			return err
+		}
+	}
+	if d.calls < len(d.answers) {
+		return unavailable
+	}
+	return nil
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	scripted := NewScriptedClient(ScriptRule{Name: "ok", Reply: "Lima"})
+	faulty := NewFaultyClient(scripted, Fault{Err: unavailable}, Fault{Err: badRequest}, Fault{Err: unavailable}, Fault{Err: unavailable})
+	client := Chain(faulty, WithCircuitBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		OpenFor:          time.Minute,
+		Now:              func() time.Time { return now },
+	}))
+	chat := func(model string) error {
+		_, err := client.Chat(context.Background(), userMessages("where?"), model)
+		return err
+	}
+
+	assert.ErrorIs(t, chat("gpt-4"), unavailable)
+	assert.ErrorIs(t, chat("gpt-4"), badRequest, "a rejected request resets the failure count")
+	assert.ErrorIs(t, chat("gpt-4"), unavailable)
+	assert.ErrorIs(t, chat("gpt-4"), unavailable)
+
+	assert.ErrorIs(t, chat("gpt-4"), ErrCircuitOpen)
+	assert.Equal(t, 4, faulty.Calls(), "an open circuit does not reach the provider")
+	assert.NoError(t, chat("gpt-4o"), "circuits are per model")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, chat("gpt-4"), "a trial call closes the circuit")
+	assert.NoError(t, chat("gpt-4"))
+}
+
+func TestWithCircuitBreaker_CountsProviderTimeouts(t *testing.T) {
+	timeout := fmt.Errorf("request: %w", context.DeadlineExceeded)
+	faulty := NewFaultyClient(NewScriptedClient(ScriptRule{Reply: "Lima"}), Fault{Err: timeout}, Fault{Err: timeout}, Fault{Err: timeout})
+	client := Chain(faulty, WithCircuitBreaker(BreakerConfig{FailureThreshold: 2, OpenFor: time.Minute}))
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := client.Chat(expired, userMessages("where?"), "gpt-4")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the caller's own deadline does not count")
+
+	for range 2 {
+		_, err = client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	_, err = client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 3, faulty.Calls())
+}
+
+func TestWithCircuitBreaker_FailedTrialReopens(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	faulty := NewFaultyClient(NewScriptedClient(ScriptRule{Reply: "Lima"}), Fault{Err: unavailable}, Fault{Err: unavailable})
+	client := Chain(faulty, WithCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		OpenFor:          time.Minute,
+		Now:              func() time.Time { return now },
+	}))
+	chat := func() error {
+		_, err := client.Chat(context.Background(), userMessages("where?"), "gpt-4")
+		return err
+	}
+
+	assert.ErrorIs(t, chat(), unavailable)
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, chat(), unavailable, "trial call")
+	assert.ErrorIs(t, chat(), ErrCircuitOpen)
+	assert.Equal(t, 2, faulty.Calls())
+}
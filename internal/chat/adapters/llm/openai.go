@@ -7,22 +7,54 @@ import (
 	"errors"
 	"fmt"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 )
 
 type OpenAIClient struct {
-	client *openai.Client
+	client       *openai.Client
+	models       *domain.ModelRegistry
+	maxToolTurns int
 }
 
-func NewOpenAIClient(apiKey string) *OpenAIClient {
-	client := openai.NewClient(option.WithAPIKey(apiKey))
+// NewOpenAIClient creates a client for the OpenAI API or, given WithBaseURL, any
+// OpenAI-compatible endpoint.
+func NewOpenAIClient(apiKey string, opts ...ClientOption) *OpenAIClient {
+	cfg := &clientConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.maxToolTurns <= 0 {
+		cfg.maxToolTurns = DefaultMaxToolIterations
+	}
+
+	client := openai.NewClient(cfg.requestOptions(apiKey)...)
 	return &OpenAIClient{
-		client: &client,
+		client:       &client,
+		models:       cfg.models,
+		maxToolTurns: cfg.maxToolTurns,
 	}
 }
 
+// resolveModel maps a model alias to the provider's model ID. Without a registry
+// the name is sent as-is, which suits servers with their own model names.
+func (o *OpenAIClient) resolveModel(alias string, needs ...domain.Capability) (string, error) {
+	if o.models == nil {
+		return alias, nil
+	}
+	spec, err := o.models.Resolve(domain.LLMModel(alias))
+	if err != nil {
+		return "", err
+	}
+	for _, need := range needs {
+		if !spec.Supports(need) {
+			return "", fmt.Errorf("model %s does not support %s", alias, need)
+		}
+	}
+	return spec.ProviderModel, nil
+}
+
 func (o *OpenAIClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
-	model, err := mapModel(model)
+	model, err := o.resolveModel(model, domain.CapabilityChat)
 	if err != nil {
 		return domain.Message{}, err
 	}
@@ -32,7 +64,6 @@ func (o *OpenAIClient) Chat(ctx context.Context, messages []domain.Message, mode
 		Model:    model,
 	})
 	if err != nil {
-		fmt.Println(err)
 		return domain.Message{}, err
 	}
 
@@ -43,13 +74,125 @@ func (o *OpenAIClient) Chat(ctx context.Context, messages []domain.Message, mode
 	return domain.NewAIMessage(messages[0].ChatID, resp.Choices[0].Message.Content), nil
 }
 
+// ChatWithTools runs the tool-calling loop: every tool call the model asks for
+// is executed, its result sent back, and the model asked again until it answers
+// without calling tools or the iteration limit is reached. With toolbox.Stream
+// set, every round is streamed and its text forwarded as it arrives.
+func (o *OpenAIClient) ChatWithTools(
+	ctx context.Context,
+	messages []domain.Message,
+	model string,
+	toolbox domain.Toolbox,
+) (domain.Message, error) {
+	if len(toolbox.Tools) == 0 && toolbox.Stream != nil {
+		return streamAnswer(ctx, o, messages, model, toolbox.Stream)
+	}
+	if len(toolbox.Tools) == 0 {
+		return o.Chat(ctx, messages, model)
+	}
+
+	needs := []domain.Capability{domain.CapabilityChat, domain.CapabilityTools}
+	if toolbox.Stream != nil {
+		needs = append(needs, domain.CapabilityStreaming)
+	}
+	model, err := o.resolveModel(model, needs...)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	tools := make([]openai.ChatCompletionToolParam, 0, len(toolbox.Tools))
+	for _, tool := range toolbox.Tools {
+		params, err := toolParameters(tool)
+		if err != nil {
+			return domain.Message{}, err
+		}
+		tools = append(tools, openai.ChatCompletionToolParam{
+			Function: openai.FunctionDefinitionParam{
+				Name:        tool.Name,
+				Description: openai.String(tool.Description),
+				Parameters:  params,
+			},
+		})
+	}
+
+	oaMessages := convertToOpenAIMessages(messages)
+	for i := 0; i < o.maxToolTurns; i++ {
+		answer, err := o.toolRound(ctx, openai.ChatCompletionNewParams{
+			Messages:          oaMessages,
+			Model:             model,
+			Tools:             tools,
+			ParallelToolCalls: openai.Bool(true),
+		}, toolbox.Stream)
+		if err != nil {
+			return domain.Message{}, err
+		}
+		if len(answer.ToolCalls) == 0 {
+			return domain.NewAIMessage(messages[0].ChatID, answer.Content), nil
+		}
+
+		calls := make([]domain.ToolCall, 0, len(answer.ToolCalls))
+		for _, call := range answer.ToolCalls {
+			calls = append(calls, domain.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: json.RawMessage(call.Function.Arguments),
+			})
+		}
+
+		oaMessages = append(oaMessages, answer.ToParam())
+		for _, call := range runToolCalls(ctx, toolbox, calls) {
+			oaMessages = append(oaMessages, openai.ToolMessage(toolResultContent(call), call.ID))
+		}
+	}
+
+	return domain.Message{}, fmt.Errorf("%w (%d)", ErrToolIterations, o.maxToolTurns)
+}
+
+// toolRound asks the model for its next move, tool calls or the answer. When
+// stream is set the response is streamed, its text forwarded as it arrives and
+// the tool calls reassembled from the deltas.
+func (o *OpenAIClient) toolRound(ctx context.Context, params openai.ChatCompletionNewParams, stream func(string) error) (openai.ChatCompletionMessage, error) {
+	if stream == nil {
+		resp, err := o.client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return openai.ChatCompletionMessage{}, err
+		}
+		if len(resp.Choices) == 0 {
+			return openai.ChatCompletionMessage{}, errors.New("no choices returned by OpenAI")
+		}
+		return resp.Choices[0].Message, nil
+	}
+
+	events := o.client.Chat.Completions.NewStreaming(ctx, params)
+	defer events.Close()
+
+	var acc openai.ChatCompletionAccumulator
+	for events.Next() {
+		chunk := events.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		if err := stream(chunk.Choices[0].Delta.Content); err != nil {
+			return openai.ChatCompletionMessage{}, err
+		}
+	}
+	if err := events.Err(); err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	if len(acc.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, errors.New("no choices returned by OpenAI")
+	}
+	return acc.Choices[0].Message, nil
+}
+
 func (o *OpenAIClient) StreamChat(
 	ctx context.Context,
 	messages []domain.Message,
 	streamFn func(string) error,
 	model string,
 ) error {
-	model, err := mapModel(model)
+	model, err := o.resolveModel(model, domain.CapabilityStreaming)
 	if err != nil {
 		return err
 	}
@@ -84,12 +227,12 @@ func (o *OpenAIClient) StructuredOutput(
 	messages []domain.Message,
 	model string,
 	schema any,
-) (map[string]string, error) {
+) (json.RawMessage, error) {
 	if schema == nil {
 		return nil, errors.New("structured output: schema is nil")
 	}
 
-	mappedModel, err := mapModel(model)
+	mappedModel, err := o.resolveModel(model, domain.CapabilityStructuredOutput)
 	if err != nil {
 		return nil, err
 	}
@@ -125,10 +268,9 @@ func (o *OpenAIClient) StructuredOutput(
 		return nil, errors.New("structured output: empty content")
 	}
 
-	var result map[string]string
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("structured output: invalid JSON returned (unmarshal failed): %w; raw=%s", err, content)
+	if !json.Valid([]byte(content)) {
+		return nil, fmt.Errorf("structured output: invalid JSON returned; raw=%s", content)
 	}
 
-	return result, nil
+	return json.RawMessage(content), nil
 }
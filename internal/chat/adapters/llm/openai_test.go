@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCompletionsServer speaks just enough of the chat completions wire format,
+// both plain JSON and SSE streaming, to stand in for an OpenAI-compatible server.
+func fakeCompletionsServer(t *testing.T, inspect func(r *http.Request, body map[string]any)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(raw, &body))
+		if inspect != nil {
+			inspect(r, body)
+		}
+
+		if stream, _ := body["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, piece := range []string{"Hola", ", ", "mundo"} {
+				fmt.Fprintf(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":%q,"+
+					"\"choices\":[{\"index\":0,\"delta\":{\"content\":%q},\"finish_reason\":null}]}\n\n", body["model"], piece)
+				w.(http.Flusher).Flush()
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"c1","object":"chat.completion","created":1,"model":%q,`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Hola mundo"},"finish_reason":"stop"}]}`, body["model"])
+	}))
+}
+
+func TestOpenAIClient_CompatibleEndpoint(t *testing.T) {
+	var got *http.Request
+	var gotBody map[string]any
+	server := fakeCompletionsServer(t, func(r *http.Request, body map[string]any) {
+		got, gotBody = r, body
+	})
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key",
+		WithBaseURL(server.URL+"/v1"),
+		WithOrganization("org-acai"),
+		WithProject("proj-travel"),
+		WithHeader("X-Gateway-Key", "secret"),
+		WithQueryParam("api-version", "2024-10-21"),
+		WithRequestTimeout(5*time.Second),
+	)
+
+	chatID := uuid.New()
+	messages := []domain.Message{
+		domain.NewSystemMessage(chatID, "be brief"),
+		domain.NewUserMessage(chatID, "hola"),
+	}
+
+	t.Run("chat", func(t *testing.T) {
+		resp, err := client.Chat(context.Background(), messages, "gpt-4o")
+		require.NoError(t, err)
+		assert.Equal(t, "Hola mundo", resp.Content)
+		assert.Equal(t, chatID, resp.ChatID)
+
+		assert.Equal(t, "/v1/chat/completions", got.URL.Path)
+		assert.Equal(t, "2024-10-21", got.URL.Query().Get("api-version"))
+		assert.Equal(t, "Bearer test-key", got.Header.Get("Authorization"))
+		assert.Equal(t, "org-acai", got.Header.Get("OpenAI-Organization"))
+		assert.Equal(t, "proj-travel", got.Header.Get("OpenAI-Project"))
+		assert.Equal(t, "secret", got.Header.Get("X-Gateway-Key"))
+		assert.Equal(t, "gpt-4o", gotBody["model"])
+		assert.Len(t, gotBody["messages"], 2)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var chunks []string
+		err := client.StreamChat(context.Background(), messages, func(s string) error {
+			chunks = append(chunks, s)
+			return nil
+		}, "gpt-4")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hola", ", ", "mundo"}, chunks)
+		assert.Equal(t, true, gotBody["stream"])
+		assert.Equal(t, "secret", got.Header.Get("X-Gateway-Key"))
+	})
+}
+
+func TestOpenAIClient_Proxy(t *testing.T) {
+	var proxied *http.Request
+	proxy := fakeCompletionsServer(t, func(r *http.Request, _ map[string]any) { proxied = r })
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	// The upstream host does not resolve: the request only succeeds through the proxy.
+	client := NewOpenAIClient("test-key", WithBaseURL("http://llm.internal.invalid/v1"), WithProxy(proxyURL))
+
+	resp, err := client.Chat(context.Background(), []domain.Message{domain.NewUserMessage(uuid.New(), "hola")}, "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "Hola mundo", resp.Content)
+	assert.Equal(t, "llm.internal.invalid", proxied.Host)
+}
+
+func TestOpenAIClient_ModelRegistry(t *testing.T) {
+	var gotModel any
+	server := fakeCompletionsServer(t, func(_ *http.Request, body map[string]any) { gotModel = body["model"] })
+	defer server.Close()
+
+	registry, err := domain.NewModelRegistry(domain.ModelSpec{
+		Alias:         "fast",
+		ProviderModel: "llama-3.1-8b-instruct",
+		Capabilities:  []domain.Capability{domain.CapabilityChat},
+	})
+	require.NoError(t, err)
+	client := NewOpenAIClient("", WithBaseURL(server.URL), WithModelRegistry(registry))
+	messages := []domain.Message{domain.NewUserMessage(uuid.New(), "hola")}
+
+	_, err = client.Chat(context.Background(), messages, "fast")
+	require.NoError(t, err)
+	assert.Equal(t, "llama-3.1-8b-instruct", gotModel)
+
+	_, err = client.Chat(context.Background(), messages, "gpt-4")
+	assert.ErrorIs(t, err, domain.ErrUnknownModel)
+
+	err = client.StreamChat(context.Background(), messages, func(string) error { return nil }, "fast")
+	assert.ErrorContains(t, err, "does not support streaming")
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// ClientOption configures how an OpenAIClient reaches its endpoint. Any server
+// that speaks the OpenAI chat completions wire format can be used: Azure
+// OpenAI, a corporate gateway or a local llama.cpp/vLLM server.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	baseURL      string
+	headers      [][2]string
+	query        [][2]string
+	organization string
+	project      string
+	timeout      time.Duration
+	proxyURL     *url.URL
+	httpClient   *http.Client
+	models       *domain.ModelRegistry
+	maxToolTurns int
+	maxRetries   *int
+}
+
+// WithBaseURL points the client at another OpenAI-compatible endpoint, e.g.
+// "http://localhost:8000/v1".
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *clientConfig) { c.baseURL = baseURL }
+}
+
+// WithHeader adds a header to every request, e.g. "api-key" for Azure OpenAI or
+// an auth header required by a gateway.
+func WithHeader(key, value string) ClientOption {
+	return func(c *clientConfig) { c.headers = append(c.headers, [2]string{key, value}) }
+}
+
+// WithQueryParam adds a query parameter to every request, e.g. Azure's "api-version".
+func WithQueryParam(key, value string) ClientOption {
+	return func(c *clientConfig) { c.query = append(c.query, [2]string{key, value}) }
+}
+
+// WithOrganization sets the OpenAI-Organization header.
+func WithOrganization(organization string) ClientOption {
+	return func(c *clientConfig) { c.organization = organization }
+}
+
+// WithProject sets the OpenAI-Project header.
+func WithProject(project string) ClientOption {
+	return func(c *clientConfig) { c.project = project }
+}
+
+// WithRequestTimeout bounds each request attempt. Streaming requests are bounded
+// for their whole duration.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) { c.timeout = timeout }
+}
+
+// WithProxy sends every request through an HTTP(S) proxy.
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(c *clientConfig) { c.proxyURL = proxyURL }
+}
+
+// WithHTTPClient replaces the underlying HTTP client. WithProxy is ignored when set.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *clientConfig) { c.httpClient = client }
+}
+
+// WithModelRegistry resolves model aliases to provider model IDs and rejects
+// calls to models that lack the capability the call needs. Without a registry,
+// model names are sent to the provider unchanged.
+func WithModelRegistry(models *domain.ModelRegistry) ClientOption {
+	return func(c *clientConfig) { c.models = models }
+}
+
+// WithMaxToolIterations caps how many rounds of tool calls a ChatWithTools call
+// may make before giving up. The default is DefaultMaxToolIterations.
+func WithMaxToolIterations(n int) ClientOption {
+	return func(c *clientConfig) { c.maxToolTurns = n }
+}
+
+// WithMaxRetries sets how many times the OpenAI SDK itself retries a failed
+// request (2 by default). Set it to 0 when the client is wrapped with WithRetry,
+// so failures are not retried twice.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *clientConfig) { c.maxRetries = &n }
+}
+
+func (c *clientConfig) requestOptions(apiKey string) []option.RequestOption {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+
+	if c.baseURL != "" {
+		opts = append(opts, option.WithBaseURL(c.baseURL))
+	}
+	if c.organization != "" {
+		opts = append(opts, option.WithOrganization(c.organization))
+	}
+	if c.project != "" {
+		opts = append(opts, option.WithProject(c.project))
+	}
+	for _, h := range c.headers {
+		opts = append(opts, option.WithHeader(h[0], h[1]))
+	}
+	for _, q := range c.query {
+		opts = append(opts, option.WithQueryAdd(q[0], q[1]))
+	}
+	if c.timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(c.timeout))
+	}
+	if c.maxRetries != nil {
+		opts = append(opts, option.WithMaxRetries(*c.maxRetries))
+	}
+
+	switch {
+	case c.httpClient != nil:
+		opts = append(opts, option.WithHTTPClient(c.httpClient))
+	case c.proxyURL != nil:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(c.proxyURL)
+		opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+
+	return opts
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the retry middleware for streams that failed after some of
+// the answer was already delivered.
+var (
+	ErrStreamInterrupted = errors.New("stream interrupted after partial output")
+	ErrStreamDiverged    = errors.New("resumed stream diverged from the delivered output")
+)
+
+// RetryPolicy configures WithRetry.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first one
+	BaseDelay   time.Duration // backoff before the second attempt, doubled on every further attempt
+	MaxDelay    time.Duration // cap on a single wait, including one asked for by Retry-After
+
+	// ResumeStreams lets a stream that failed after delivering chunks be retried.
+	// The new answer must start with what was already delivered, which is then
+	// skipped; otherwise the call fails with ErrStreamDiverged. Without it such
+	// a stream fails with ErrStreamInterrupted.
+	ResumeStreams bool
+
+	// Sleep waits between attempts; tests replace it to avoid real delays.
+	Sleep func(ctx context.Context, d time.Duration) error
+	// Rand returns a number in [0, 1) used to jitter the backoff.
+	Rand func() float64
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, waiting up to 0.5s and then 1s
+// between them unless the provider asks for longer.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 20 * time.Second}
+}
+
+// backoff returns the wait before the given retry (1 for the first retry),
+// using full jitter so that clients failing together do not retry together.
+func (p RetryPolicy) backoff(retry int, failure Failure) time.Duration {
+	if failure.RetryAfter > 0 {
+		return min(failure.RetryAfter, p.MaxDelay)
+	}
+	ceiling := p.BaseDelay << (retry - 1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(p.Rand() * float64(ceiling))
+}
+
+// WithRetry retries calls that fail with a retryable error. Calls whose tools
+// already ran are not retried, since tools may have side effects, and neither
+// are calls that already streamed part of their answer.
+func WithRetry(policy RetryPolicy) Middleware {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRetryPolicy().MaxDelay
+	}
+	if policy.Sleep == nil {
+		policy.Sleep = sleep
+	}
+	if policy.Rand == nil {
+		policy.Rand = rand.Float64
+	}
+	return func(inner domain.LLMClient) domain.LLMClient {
+		return &retryingClient{inner: inner, policy: policy}
+	}
+}
+
+type retryingClient struct {
+	inner  domain.LLMClient
+	policy RetryPolicy
+}
+
+// do runs call until it succeeds, fails with an error that is not worth
+// retrying, or runs out of attempts.
+func (c *retryingClient) do(ctx context.Context, kind, model string, call func() (retry bool, err error)) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = call()
+		if err == nil || !retry || attempt == c.policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		failure := Classify(err)
+		if !failure.Retryable {
+			return err
+		}
+		wait := c.policy.backoff(attempt, failure)
+		log.Printf("llm: %s call to %s failed (attempt %d/%d), retrying in %s: %v", kind, model, attempt, c.policy.MaxAttempts, wait, err)
+		if sleepErr := c.policy.Sleep(ctx, wait); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (c *retryingClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	var resp domain.Message
+	err := c.do(ctx, "chat", model, func() (bool, error) {
+		var err error
+		resp, err = c.inner.Chat(ctx, messages, model)
+		return true, err
+	})
+	return resp, err
+}
+
+func (c *retryingClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	var resp json.RawMessage
+	err := c.do(ctx, "structured", model, func() (bool, error) {
+		var err error
+		resp, err = c.inner.StructuredOutput(ctx, messages, model, schema)
+		return true, err
+	})
+	return resp, err
+}
+
+func (c *retryingClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	var mu sync.Mutex
+	var toolsRan, streamed bool
+	observed := toolbox
+	observed.Observe = func(tool domain.Tool, call domain.ToolCall) {
+		mu.Lock()
+		toolsRan = true
+		mu.Unlock()
+		if toolbox.Observe != nil {
+			toolbox.Observe(tool, call)
+		}
+	}
+	if toolbox.Stream != nil {
+		observed.Stream = func(chunk string) error {
+			mu.Lock()
+			streamed = true
+			mu.Unlock()
+			return toolbox.Stream(chunk)
+		}
+	}
+
+	var resp domain.Message
+	err := c.do(ctx, "tools", model, func() (bool, error) {
+		var err error
+		resp, err = c.inner.ChatWithTools(ctx, messages, model, observed)
+		mu.Lock()
+		defer mu.Unlock()
+		return !toolsRan && !streamed, err
+	})
+	return resp, err
+}
+
+// StreamChat retries a stream that failed before delivering anything. Once
+// chunks reached streamFn, the policy's ResumeStreams rule applies, and errors
+// returned by streamFn itself are never retried.
+func (c *retryingClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	var delivered strings.Builder
+	var consumerErr error
+
+	return c.do(ctx, "stream", model, func() (bool, error) {
+		// skip is the part of this attempt's answer already delivered by an
+		// earlier one; received tracks how much of it this attempt has produced.
+		skip := delivered.String()
+		var received strings.Builder
+
+		err := c.inner.StreamChat(ctx, messages, func(chunk string) error {
+			if received.Len() < len(skip) {
+				received.WriteString(chunk)
+				got := received.String()
+				if len(got) <= len(skip) {
+					if !strings.HasPrefix(skip, got) {
+						return ErrStreamDiverged
+					}
+					return nil
+				}
+				if !strings.HasPrefix(got, skip) {
+					return ErrStreamDiverged
+				}
+				chunk = got[len(skip):]
+			} else {
+				received.WriteString(chunk)
+			}
+
+			if err := streamFn(chunk); err != nil {
+				consumerErr = err
+				return err
+			}
+			delivered.WriteString(chunk)
+			return nil
+		}, model)
+
+		switch {
+		case err == nil && received.Len() < len(skip):
+			return false, ErrStreamDiverged
+		case err == nil:
+			return false, nil
+		case consumerErr != nil, errors.Is(err, ErrStreamDiverged):
+			return false, err
+		case delivered.Len() > 0 && !c.policy.ResumeStreams:
+			return false, fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+		}
+		return true, err
+	})
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
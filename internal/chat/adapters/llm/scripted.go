@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ScriptRule answers every call whose messages match it. Empty matchers match
+// anything, so a rule with only a Reply is a catch-all.
+type ScriptRule struct {
+	Name             string // used in Calls and error messages
+	SystemContains   string // substring of the system prompt, e.g. a phrase from an agent template
+	LastUserContains string // substring of the last user message
+
+	Reply      string            // Chat answer, and the streamed answer when Chunks is empty
+	Chunks     []string          // chunks of a streamed answer, from StreamChat or a streaming ChatWithTools
+	Structured any               // StructuredOutput answer, encoded as JSON
+	ToolCalls  []domain.ToolCall // tools ChatWithTools calls, in one parallel round, before answering Reply
+	Err        error             // returned instead of an answer
+}
+
+func (r ScriptRule) matches(messages []domain.Message) bool {
+	if r.SystemContains != "" && !strings.Contains(systemPrompt(messages), r.SystemContains) {
+		return false
+	}
+	if r.LastUserContains != "" && !strings.Contains(lastUserMessage(messages), r.LastUserContains) {
+		return false
+	}
+	return true
+}
+
+// ScriptedCall is a call received by a ScriptedClient.
+type ScriptedCall struct {
+	Kind      string // "chat", "stream", "structured" or "tools"
+	Rule      string
+	Model     string
+	Messages  []domain.Message
+	ToolCalls []domain.ToolCall // the rule's tool calls with their results, for "tools" calls
+}
+
+// ScriptedClient is a domain.LLMClient that answers from a fixed script instead
+// of a provider, for offline tests. The first matching rule wins.
+type ScriptedClient struct {
+	rules []ScriptRule
+
+	mu    sync.Mutex
+	calls []ScriptedCall
+}
+
+func NewScriptedClient(rules ...ScriptRule) *ScriptedClient {
+	return &ScriptedClient{rules: rules}
+}
+
+// Calls returns every call received so far, in arrival order.
+func (c *ScriptedClient) Calls() []ScriptedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ScriptedCall(nil), c.calls...)
+}
+
+func (c *ScriptedClient) match(ctx context.Context, kind, model string, messages []domain.Message) (ScriptRule, error) {
+	rule, err := c.find(ctx, kind, messages)
+	if err != nil {
+		return rule, err
+	}
+	c.record(ScriptedCall{Kind: kind, Rule: rule.Name, Model: model, Messages: messages})
+	return rule, rule.Err
+}
+
+// find returns the rule answering a call. Like a provider, it refuses calls
+// whose context is already done.
+func (c *ScriptedClient) find(ctx context.Context, kind string, messages []domain.Message) (ScriptRule, error) {
+	if err := ctx.Err(); err != nil {
+		return ScriptRule{}, err
+	}
+	for _, rule := range c.rules {
+		if rule.matches(messages) {
+			return rule, nil
+		}
+	}
+	return ScriptRule{}, fmt.Errorf("scripted client: no rule matches %s call (system prompt %q)", kind, truncate(systemPrompt(messages), 80))
+}
+
+func (c *ScriptedClient) record(call ScriptedCall) {
+	c.mu.Lock()
+	c.calls = append(c.calls, call)
+	c.mu.Unlock()
+}
+
+func (c *ScriptedClient) Chat(ctx context.Context, messages []domain.Message, model string) (domain.Message, error) {
+	rule, err := c.match(ctx, "chat", model, messages)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	return domain.NewAIMessage(messages[0].ChatID, rule.Reply), nil
+}
+
+func (c *ScriptedClient) ChatWithTools(ctx context.Context, messages []domain.Message, model string, toolbox domain.Toolbox) (domain.Message, error) {
+	rule, err := c.find(ctx, "tools", messages)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	call := ScriptedCall{Kind: "tools", Rule: rule.Name, Model: model, Messages: messages}
+	if rule.Err == nil && len(rule.ToolCalls) > 0 {
+		call.ToolCalls = runToolCalls(ctx, toolbox, rule.ToolCalls)
+	}
+	c.record(call)
+
+	if rule.Err != nil {
+		return domain.Message{}, rule.Err
+	}
+	if toolbox.Stream == nil {
+		return domain.NewAIMessage(messages[0].ChatID, rule.Reply), nil
+	}
+	if err := streamChunks(ctx, rule, toolbox.Stream); err != nil {
+		return domain.Message{}, err
+	}
+	return domain.NewAIMessage(messages[0].ChatID, strings.Join(rule.chunks(), "")), nil
+}
+
+func (c *ScriptedClient) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error, model string) error {
+	rule, err := c.match(ctx, "stream", model, messages)
+	if err != nil {
+		return err
+	}
+
+	return streamChunks(ctx, rule, streamFn)
+}
+
+// chunks is the rule's streamed answer: Chunks, or Reply in one piece.
+func (r ScriptRule) chunks() []string {
+	if len(r.Chunks) == 0 {
+		return []string{r.Reply}
+	}
+	return r.Chunks
+}
+
+func streamChunks(ctx context.Context, rule ScriptRule, streamFn func(string) error) error {
+	for _, chunk := range rule.chunks() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := streamFn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ScriptedClient) StructuredOutput(ctx context.Context, messages []domain.Message, model string, schema any) (json.RawMessage, error) {
+	rule, err := c.match(ctx, "structured", model, messages)
+	if err != nil {
+		return nil, err
+	}
+	if raw, ok := rule.Structured.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(rule.Structured)
+}
+
+func systemPrompt(messages []domain.Message) string {
+	for _, m := range messages {
+		if m.Sender == domain.SenderSystem {
+			return m.Content
+		}
+	}
+	return ""
+}
+
+func lastUserMessage(messages []domain.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Sender == domain.SenderUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
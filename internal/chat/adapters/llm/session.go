@@ -3,6 +3,7 @@ package llm
 import (
 	"acai_travel/internal/chat/domain"
 	"context"
+	"encoding/json"
 )
 
 type LLMModelSession[T domain.LLMClient] struct {
@@ -21,6 +22,18 @@ func (s *LLMModelSession[T]) Chat(ctx context.Context, messages []domain.Message
 	return s.client.Chat(ctx, messages, s.model)
 }
 
+// ChatWithTools offers the toolbox to the model; without tools it is a plain
+// Chat, or StreamChat when the toolbox asks for the answer to be streamed.
+func (s *LLMModelSession[T]) ChatWithTools(ctx context.Context, messages []domain.Message, toolbox domain.Toolbox) (domain.Message, error) {
+	if len(toolbox.Tools) == 0 && toolbox.Stream != nil {
+		return streamAnswer(ctx, s.client, messages, s.model, toolbox.Stream)
+	}
+	if len(toolbox.Tools) == 0 {
+		return s.client.Chat(ctx, messages, s.model)
+	}
+	return s.client.ChatWithTools(ctx, messages, s.model, toolbox)
+}
+
 func (s *LLMModelSession[T]) StreamChat(ctx context.Context, messages []domain.Message, streamFn func(string) error) error {
 	return s.client.StreamChat(ctx, messages, streamFn, s.model)
 }
@@ -29,6 +42,6 @@ func (s *LLMModelSession[T]) StructuredOutput(
 	ctx context.Context,
 	messages []domain.Message,
 	schema any,
-) (map[string]string, error) {
+) (json.RawMessage, error) {
 	return s.client.StructuredOutput(ctx, messages, s.model, schema)
 }
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/invopop/jsonschema"
+)
+
+// StructuredOutput asks the session's model for a JSON answer whose strict
+// schema is derived from T, then decodes it into T. Unknown fields and
+// missing required fields are rejected, so a successful call always yields a
+// fully populated T.
+func StructuredOutput[T any, C domain.LLMClient](ctx context.Context, s *LLMModelSession[C], messages []domain.Message) (T, error) {
+	var out T
+	err := StructuredOutputInto(ctx, s, messages, &out)
+	return out, err
+}
+
+// StructuredOutputInto is StructuredOutput for a type only known at run time:
+// the schema is derived from the type out points to, and the answer is decoded
+// into it.
+func StructuredOutputInto[C domain.LLMClient](ctx context.Context, s *LLMModelSession[C], messages []domain.Message, out any) error {
+	schema := schemaOf(out)
+	raw, err := s.StructuredOutput(ctx, messages, schema)
+	if err != nil {
+		return err
+	}
+	return DecodeStructured(raw, schema, out)
+}
+
+// DecodeStructured validates raw against the required fields of schema and
+// decodes it into out.
+func DecodeStructured(raw json.RawMessage, schema *jsonschema.Schema, out any) error {
+	if err := checkRequired(raw, schema, "$"); err != nil {
+		return fmt.Errorf("structured output: %w; raw=%s", err, raw)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("structured output: decode failed: %w; raw=%s", err, raw)
+	}
+	return nil
+}
+
+func reflectSchema[T any]() *jsonschema.Schema {
+	return GenerateSchema[T]().(*jsonschema.Schema)
+}
+
+// checkRequired walks the value alongside its schema and reports the first
+// required property that is missing or null.
+func checkRequired(raw json.RawMessage, schema *jsonschema.Schema, path string) error {
+	if schema == nil {
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("%s: expected object: %w", path, err)
+		}
+		for _, name := range schema.Required {
+			value, ok := fields[name]
+			if !ok || string(value) == "null" {
+				return fmt.Errorf("%s.%s: required field missing", path, name)
+			}
+		}
+		if schema.Properties == nil {
+			return nil
+		}
+		for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			value, ok := fields[pair.Key]
+			if !ok {
+				continue
+			}
+			if err := checkRequired(value, pair.Value, path+"."+pair.Key); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return fmt.Errorf("%s: expected array: %w", path, err)
+		}
+		for i, item := range items {
+			if err := checkRequired(item, schema.Items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
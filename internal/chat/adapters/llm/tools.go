@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxToolIterations is how many rounds of tool calls a model may make
+// in one ChatWithTools call unless WithMaxToolIterations says otherwise.
+const DefaultMaxToolIterations = 5
+
+// ErrToolIterations is returned when a model keeps calling tools past the limit.
+var ErrToolIterations = errors.New("tool calling: too many iterations")
+
+// runToolCalls runs the calls requested in one model turn concurrently, since
+// models batch independent lookups, and returns them with their results in the
+// order they were requested. Failures are reported back to the model as the
+// call's result rather than aborting the conversation, so it can recover.
+func runToolCalls(ctx context.Context, toolbox domain.Toolbox, calls []domain.ToolCall) []domain.ToolCall {
+	done := make([]domain.ToolCall, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call domain.ToolCall) {
+			defer wg.Done()
+			done[i] = runToolCall(ctx, toolbox, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return done
+}
+
+func runToolCall(ctx context.Context, toolbox domain.Toolbox, call domain.ToolCall) domain.ToolCall {
+	tool, ok := toolbox.Find(call.Name)
+	if !ok {
+		call.Error = fmt.Sprintf("unknown tool %q", call.Name)
+		return call
+	}
+	if toolbox.Observe != nil {
+		toolbox.Observe(tool, call)
+	}
+
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	result, err := tool.Handler(ctx, args)
+	if err != nil {
+		call.Error = err.Error()
+		return call
+	}
+	call.Result = result
+	return call
+}
+
+// streamAnswer answers a call that offers no tools by streaming a plain
+// completion to stream, returning the whole answer once it is written.
+func streamAnswer(ctx context.Context, client domain.LLMClient, messages []domain.Message, model string, stream func(string) error) (domain.Message, error) {
+	var answer strings.Builder
+	err := client.StreamChat(ctx, messages, func(chunk string) error {
+		answer.WriteString(chunk)
+		return stream(chunk)
+	}, model)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	return domain.NewAIMessage(messages[0].ChatID, answer.String()), nil
+}
+
+// toolResultContent is what the model sees for a finished call.
+func toolResultContent(call domain.ToolCall) string {
+	if call.Error != "" {
+		return "error: " + call.Error
+	}
+	return call.Result
+}
+
+// toolParameters converts a tool's schema into the plain JSON object expected
+// by the provider, whatever type it was declared with.
+func toolParameters(tool domain.Tool) (map[string]any, error) {
+	if tool.Parameters == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}, nil
+	}
+	raw, err := json.Marshal(tool.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: encode parameters: %w", tool.Name, err)
+	}
+	var params map[string]any
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, fmt.Errorf("tool %s: parameters must be a JSON object: %w", tool.Name, err)
+	}
+	return params, nil
+}
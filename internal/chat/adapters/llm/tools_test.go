@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCallingServer asks for two parallel tool calls until it has seen their
+// results, then answers with the results it received. With loop set it never
+// stops calling tools. Streaming requests get the same turns as SSE deltas.
+func toolCallingServer(t *testing.T, loop bool, requests *[]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(raw, &body))
+		*requests = append(*requests, body)
+
+		messages := body["messages"].([]any)
+		last := messages[len(messages)-1].(map[string]any)
+
+		if stream, _ := body["stream"].(bool); stream {
+			writeToolStream(w, last["role"] == "tool" && !loop)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if last["role"] == "tool" && !loop {
+			var results []string
+			for _, m := range messages {
+				if msg := m.(map[string]any); msg["role"] == "tool" {
+					results = append(results, fmt.Sprintf("%s=%s", msg["tool_call_id"], msg["content"]))
+				}
+			}
+			answer, _ := json.Marshal(fmt.Sprint(results))
+			fmt.Fprintf(w, `{"id":"c2","object":"chat.completion","created":1,"model":"gpt-4o",`+
+				`"choices":[{"index":0,"message":{"role":"assistant","content":%s},"finish_reason":"stop"}]}`, answer)
+			return
+		}
+
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[`+
+			`{"id":"call-lima","type":"function","function":{"name":"check_prices","arguments":"{\"city\":\"Lima\"}"}},`+
+			`{"id":"call-cusco","type":"function","function":{"name":"check_prices","arguments":"{\"city\":\"Cusco\"}"}}`+
+			`]},"finish_reason":"tool_calls"}]}`)
+	}))
+}
+
+// writeToolStream streams either the final answer in two pieces or the two
+// tool calls, with the first call's arguments split across deltas.
+func writeToolStream(w http.ResponseWriter, answer bool) {
+	deltas := []string{
+		`{"role":"assistant","tool_calls":[{"index":0,"id":"call-lima","type":"function","function":{"name":"check_prices","arguments":"{\"city\":"}}]}`,
+		`{"tool_calls":[{"index":0,"function":{"arguments":"\"Lima\"}"}}]}`,
+		`{"tool_calls":[{"index":1,"id":"call-cusco","type":"function","function":{"name":"check_prices","arguments":"{\"city\":\"Cusco\"}"}}]}`,
+	}
+	if answer {
+		deltas = []string{`{"role":"assistant","content":"Hostels from "}`, `{"content":"$25 a night."}`}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, delta := range deltas {
+		fmt.Fprintf(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\","+
+			"\"choices\":[{\"index\":0,\"delta\":%s,\"finish_reason\":null}]}\n\n", delta)
+		w.(http.Flusher).Flush()
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func priceTool(observed *[]string, mu *sync.Mutex) domain.Toolbox {
+	type args struct {
+		City string `json:"city"`
+	}
+	return domain.Toolbox{
+		Tools: []domain.Tool{{
+			Name:        "check_prices",
+			Description: "Hostel prices per night",
+			Parameters:  GenerateSchema[args](),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var a args
+				if err := json.Unmarshal(arguments, &a); err != nil {
+					return "", err
+				}
+				return "$25 in " + a.City, nil
+			},
+		}},
+		Observe: func(tool domain.Tool, call domain.ToolCall) {
+			mu.Lock()
+			*observed = append(*observed, call.ID)
+			mu.Unlock()
+		},
+	}
+}
+
+func TestOpenAIClient_ChatWithTools(t *testing.T) {
+	var requests []map[string]any
+	server := toolCallingServer(t, false, &requests)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var observed []string
+	client := NewOpenAIClient("test-key", WithBaseURL(server.URL+"/v1"))
+
+	chatID := uuid.New()
+	resp, err := client.ChatWithTools(context.Background(), []domain.Message{
+		domain.NewUserMessage(chatID, "How much is a hostel in Peru?"),
+	}, "gpt-4o", priceTool(&observed, &mu))
+	require.NoError(t, err)
+
+	assert.Equal(t, "[call-lima=$25 in Lima call-cusco=$25 in Cusco]", resp.Content)
+	assert.ElementsMatch(t, []string{"call-lima", "call-cusco"}, observed)
+
+	require.Len(t, requests, 2)
+	tools := requests[0]["tools"].([]any)
+	function := tools[0].(map[string]any)["function"].(map[string]any)
+	assert.Equal(t, "check_prices", function["name"])
+	assert.Contains(t, function["parameters"].(map[string]any)["properties"], "city")
+	assert.Equal(t, true, requests[0]["parallel_tool_calls"])
+
+	second := requests[1]["messages"].([]any)
+	require.Len(t, second, 4, "user message, assistant tool calls and one result per call")
+	assert.NotEmpty(t, second[1].(map[string]any)["tool_calls"])
+}
+
+func TestOpenAIClient_ChatWithToolsStreams(t *testing.T) {
+	var requests []map[string]any
+	server := toolCallingServer(t, false, &requests)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var observed, chunks []string
+	toolbox := priceTool(&observed, &mu)
+	toolbox.Stream = func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}
+	client := NewOpenAIClient("test-key", WithBaseURL(server.URL+"/v1"))
+
+	resp, err := client.ChatWithTools(context.Background(), []domain.Message{
+		domain.NewUserMessage(uuid.New(), "How much is a hostel in Peru?"),
+	}, "gpt-4o", toolbox)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Hostels from ", "$25 a night."}, chunks)
+	assert.Equal(t, "Hostels from $25 a night.", resp.Content)
+	assert.ElementsMatch(t, []string{"call-lima", "call-cusco"}, observed)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, true, requests[0]["stream"])
+	second := requests[1]["messages"].([]any)
+	require.Len(t, second, 4)
+	calls := second[1].(map[string]any)["tool_calls"].([]any)
+	assert.Equal(t, `{"city":"Lima"}`, calls[0].(map[string]any)["function"].(map[string]any)["arguments"], "arguments are reassembled from the deltas")
+	assert.Equal(t, "$25 in Lima", second[2].(map[string]any)["content"])
+}
+
+func TestOpenAIClient_ChatWithToolsStopsAfterMaxIterations(t *testing.T) {
+	var requests []map[string]any
+	server := toolCallingServer(t, true, &requests)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var observed []string
+	client := NewOpenAIClient("test-key", WithBaseURL(server.URL+"/v1"), WithMaxToolIterations(3))
+
+	_, err := client.ChatWithTools(context.Background(), []domain.Message{
+		domain.NewUserMessage(uuid.New(), "How much is a hostel in Peru?"),
+	}, "gpt-4o", priceTool(&observed, &mu))
+
+	assert.True(t, errors.Is(err, ErrToolIterations))
+	assert.Len(t, requests, 3)
+}
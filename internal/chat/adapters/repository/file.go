@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// FileStore persists each conversation as a JSON document named after its ID
+// inside a directory. Writes go through a temp file and a rename so a crash
+// never leaves a half-written conversation behind.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file store: create %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, chat *domain.Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.read(chat.ID)
+	switch {
+	case errors.Is(err, application.ErrConversationNotFound):
+		record = &conversationRecord{}
+	case err != nil:
+		return err
+	case record.Chat.UserID != chat.UserID:
+		return application.ErrConversationNotOwned
+	}
+
+	record.Chat = toChatRecord(chat)
+	return s.write(chat.ID, record)
+}
+
+func (s *FileStore) Create(ctx context.Context, chat *domain.Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.read(chat.ID)
+	switch {
+	case err == nil:
+		return application.ErrConversationExists
+	case !errors.Is(err, application.ErrConversationNotFound):
+		return err
+	}
+	return s.write(chat.ID, &conversationRecord{Chat: toChatRecord(chat)})
+}
+
+func (s *FileStore) Load(ctx context.Context, conversationID, userID uuid.UUID) (*domain.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return record.Chat.toDomain(), nil
+}
+
+func (s *FileStore) AppendMessages(ctx context.Context, conversationID, userID uuid.UUID, messages ...domain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	chat := record.Chat.toDomain()
+	for _, m := range messages {
+		if err := chat.AddMessage(m); err != nil {
+			return err
+		}
+	}
+
+	record.Chat = toChatRecord(chat)
+	return s.write(conversationID, record)
+}
+
+func (s *FileStore) LoadTripState(ctx context.Context, conversationID, userID uuid.UUID) (*domain.TripState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if record.State == nil {
+		return nil, application.ErrTripStateNotFound
+	}
+	return record.State.toDomain(conversationID, userID), nil
+}
+
+func (s *FileStore) SaveTripState(ctx context.Context, state *domain.TripState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(state.ConversationID, state.UserID)
+	if err != nil {
+		return err
+	}
+
+	record.State = toTripStateRecord(state)
+	return s.write(state.ConversationID, record)
+}
+
+func (s *FileStore) SaveAgentSession(ctx context.Context, userID uuid.UUID, session *domain.AgentSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(session.ConversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	record.Sessions = append(record.Sessions, toAgentSessionRecord(session))
+	return s.write(session.ConversationID, record)
+}
+
+func (s *FileStore) ListAgentSessions(ctx context.Context, conversationID, userID uuid.UUID) ([]*domain.AgentSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.readOwned(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*domain.AgentSession, 0, len(record.Sessions))
+	for _, r := range record.Sessions {
+		sessions = append(sessions, r.toDomain(conversationID))
+	}
+	return sessions, nil
+}
+
+func (s *FileStore) path(id uuid.UUID) string {
+	return filepath.Join(s.dir, id.String()+".json")
+}
+
+func (s *FileStore) readOwned(id, userID uuid.UUID) (*conversationRecord, error) {
+	record, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Chat.UserID != userID {
+		return nil, application.ErrConversationNotOwned
+	}
+	return record, nil
+}
+
+func (s *FileStore) read(id uuid.UUID) (*conversationRecord, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, application.ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file store: read %s: %w", id, err)
+	}
+
+	var record conversationRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("file store: decode %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+func (s *FileStore) write(id uuid.UUID, record *conversationRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("file store: encode %s: %w", id, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id.String()+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("file store: write %s: %w", id, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("file store: write %s: %w", id, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("file store: write %s: %w", id, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return fmt.Errorf("file store: write %s: %w", id, err)
+	}
+	return nil
+}
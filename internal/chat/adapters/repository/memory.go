@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps conversations in process memory. Data is lost on restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	chats    map[uuid.UUID]*domain.Chat
+	states   map[uuid.UUID]*domain.TripState
+	sessions map[uuid.UUID][]*domain.AgentSession
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		chats:    make(map[uuid.UUID]*domain.Chat),
+		states:   make(map[uuid.UUID]*domain.TripState),
+		sessions: make(map[uuid.UUID][]*domain.AgentSession),
+	}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, chat *domain.Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.chats[chat.ID]; ok && existing.UserID != chat.UserID {
+		return application.ErrConversationNotOwned
+	}
+	s.chats[chat.ID] = cloneChat(chat)
+	return nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, chat *domain.Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[chat.ID]; ok {
+		return application.ErrConversationExists
+	}
+	s.chats[chat.ID] = cloneChat(chat)
+	return nil
+}
+
+func (s *MemoryStore) Load(ctx context.Context, conversationID, userID uuid.UUID) (*domain.Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[conversationID]
+	if !ok {
+		return nil, application.ErrConversationNotFound
+	}
+	if chat.UserID != userID {
+		return nil, application.ErrConversationNotOwned
+	}
+	return cloneChat(chat), nil
+}
+
+func (s *MemoryStore) AppendMessages(ctx context.Context, conversationID, userID uuid.UUID, messages ...domain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[conversationID]
+	if !ok {
+		return application.ErrConversationNotFound
+	}
+	if chat.UserID != userID {
+		return application.ErrConversationNotOwned
+	}
+	for _, m := range messages {
+		if err := chat.AddMessage(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LoadTripState(ctx context.Context, conversationID, userID uuid.UUID) (*domain.TripState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkOwner(conversationID, userID); err != nil {
+		return nil, err
+	}
+	state, ok := s.states[conversationID]
+	if !ok {
+		return nil, application.ErrTripStateNotFound
+	}
+	return cloneTripState(state), nil
+}
+
+func (s *MemoryStore) SaveTripState(ctx context.Context, state *domain.TripState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkOwner(state.ConversationID, state.UserID); err != nil {
+		return err
+	}
+	s.states[state.ConversationID] = cloneTripState(state)
+	return nil
+}
+
+func (s *MemoryStore) SaveAgentSession(ctx context.Context, userID uuid.UUID, session *domain.AgentSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkOwner(session.ConversationID, userID); err != nil {
+		return err
+	}
+	s.sessions[session.ConversationID] = append(s.sessions[session.ConversationID], cloneAgentSession(session))
+	return nil
+}
+
+func (s *MemoryStore) ListAgentSessions(ctx context.Context, conversationID, userID uuid.UUID) ([]*domain.AgentSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkOwner(conversationID, userID); err != nil {
+		return nil, err
+	}
+	sessions := make([]*domain.AgentSession, 0, len(s.sessions[conversationID]))
+	for _, session := range s.sessions[conversationID] {
+		sessions = append(sessions, cloneAgentSession(session))
+	}
+	return sessions, nil
+}
+
+// checkOwner must be called with s.mu held.
+func (s *MemoryStore) checkOwner(conversationID, userID uuid.UUID) error {
+	chat, ok := s.chats[conversationID]
+	if !ok {
+		return application.ErrConversationNotFound
+	}
+	if chat.UserID != userID {
+		return application.ErrConversationNotOwned
+	}
+	return nil
+}
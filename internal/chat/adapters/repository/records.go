@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"acai_travel/internal/chat/domain"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// conversationRecord is the persisted shape of a single conversation.
+type conversationRecord struct {
+	Chat     chatRecord           `json:"chat"`
+	State    *tripStateRecord     `json:"state,omitempty"`
+	Sessions []agentSessionRecord `json:"sessions,omitempty"`
+}
+
+type chatRecord struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"userId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Messages  []messageRecord `json:"messages"`
+}
+
+type messageRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Sender    string    `json:"sender"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func toChatRecord(chat *domain.Chat) chatRecord {
+	messages := make([]messageRecord, 0, len(chat.Messages))
+	for _, m := range chat.Messages {
+		messages = append(messages, messageRecord{
+			ID:        m.ID,
+			Sender:    m.Sender.String(),
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+		})
+	}
+	return chatRecord{
+		ID:        chat.ID,
+		UserID:    chat.UserID,
+		CreatedAt: chat.CreatedAt,
+		Messages:  messages,
+	}
+}
+
+func (r chatRecord) toDomain() *domain.Chat {
+	messages := make([]domain.Message, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		messages = append(messages, domain.Message{
+			ID:        m.ID,
+			ChatID:    r.ID,
+			Sender:    domain.MessageSender(m.Sender),
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+		})
+	}
+	return &domain.Chat{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		CreatedAt: r.CreatedAt,
+		Messages:  messages,
+	}
+}
+
+// cloneChat returns a deep copy so callers never share message slices with the store.
+func cloneChat(chat *domain.Chat) *domain.Chat {
+	clone := *chat
+	clone.Messages = append([]domain.Message(nil), chat.Messages...)
+	return &clone
+}
+
+type tripStateRecord struct {
+	Destinations []string                     `json:"destinations"`
+	Preferences  []string                     `json:"preferences"`
+	Interest     []string                     `json:"interest"`
+	AgentResults map[string]agentResultRecord `json:"agentResults"`
+	// The domain type already has the JSON shape clients receive.
+	PendingClarification *domain.Clarification `json:"pendingClarification,omitempty"`
+	BlockedMessages      []uuid.UUID           `json:"blockedMessages,omitempty"`
+	Locale               string                `json:"locale,omitempty"`
+	Currency             string                `json:"currency,omitempty"`
+	UpdatedAt            time.Time             `json:"updatedAt"`
+}
+
+type agentResultRecord struct {
+	InputKey string `json:"inputKey"`
+	Output   string `json:"output"`
+}
+
+func toTripStateRecord(state *domain.TripState) *tripStateRecord {
+	results := make(map[string]agentResultRecord, len(state.AgentResults))
+	for agent, r := range state.AgentResults {
+		results[string(agent)] = agentResultRecord{InputKey: r.InputKey, Output: r.Output}
+	}
+	return &tripStateRecord{
+		Destinations:         state.Intent.Destinations,
+		Preferences:          state.Intent.Preferences,
+		Interest:             state.Intent.Interest,
+		AgentResults:         results,
+		PendingClarification: cloneClarification(state.PendingClarification),
+		BlockedMessages:      slices.Clone(state.BlockedMessages),
+		Locale:               string(state.Locale),
+		Currency:             string(state.Currency),
+		UpdatedAt:            state.UpdatedAt,
+	}
+}
+
+func (r *tripStateRecord) toDomain(conversationID, userID uuid.UUID) *domain.TripState {
+	results := make(map[domain.Agent]domain.AgentResult, len(r.AgentResults))
+	for agent, res := range r.AgentResults {
+		results[domain.Agent(agent)] = domain.AgentResult{InputKey: res.InputKey, Output: res.Output}
+	}
+	return &domain.TripState{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Intent: domain.TravelIntent{
+			Destinations: r.Destinations,
+			Preferences:  r.Preferences,
+			Interest:     r.Interest,
+		},
+		AgentResults:         results,
+		PendingClarification: cloneClarification(r.PendingClarification),
+		BlockedMessages:      slices.Clone(r.BlockedMessages),
+		Locale:               domain.Locale(r.Locale),
+		Currency:             domain.Currency(r.Currency),
+		UpdatedAt:            r.UpdatedAt,
+	}
+}
+
+func cloneTripState(state *domain.TripState) *domain.TripState {
+	clone := *state
+	clone.Intent = domain.TravelIntent{
+		Destinations: append([]string(nil), state.Intent.Destinations...),
+		Preferences:  append([]string(nil), state.Intent.Preferences...),
+		Interest:     append([]string(nil), state.Intent.Interest...),
+	}
+	clone.AgentResults = make(map[domain.Agent]domain.AgentResult, len(state.AgentResults))
+	for agent, r := range state.AgentResults {
+		clone.AgentResults[agent] = r
+	}
+	clone.PendingClarification = cloneClarification(state.PendingClarification)
+	clone.BlockedMessages = slices.Clone(state.BlockedMessages)
+	return &clone
+}
+
+func cloneClarification(c *domain.Clarification) *domain.Clarification {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.Fields = append([]domain.IntentField(nil), c.Fields...)
+	return &clone
+}
+
+type agentSessionRecord struct {
+	ID              uuid.UUID         `json:"id"`
+	TurnID          uuid.UUID         `json:"turnId"`
+	Agent           string            `json:"agent"`
+	TemplateVersion string            `json:"templateVersion,omitempty"`
+	Inputs          map[string]string `json:"inputs"`
+	Chat            *chatRecord       `json:"chat,omitempty"`
+	Output          string            `json:"output"`
+	Error           string            `json:"error,omitempty"`
+	StartedAt       time.Time         `json:"startedAt"`
+	FinishedAt      time.Time         `json:"finishedAt"`
+}
+
+func toAgentSessionRecord(session *domain.AgentSession) agentSessionRecord {
+	record := agentSessionRecord{
+		ID:              session.ID,
+		TurnID:          session.TurnID,
+		Agent:           string(session.Agent),
+		TemplateVersion: session.TemplateVersion,
+		Inputs:          session.Inputs,
+		Output:          session.Output,
+		Error:           session.Error,
+		StartedAt:       session.StartedAt,
+		FinishedAt:      session.FinishedAt,
+	}
+	if session.Chat != nil {
+		chat := toChatRecord(session.Chat)
+		record.Chat = &chat
+	}
+	return record
+}
+
+func (r agentSessionRecord) toDomain(conversationID uuid.UUID) *domain.AgentSession {
+	session := &domain.AgentSession{
+		ID:              r.ID,
+		ConversationID:  conversationID,
+		TurnID:          r.TurnID,
+		Agent:           domain.Agent(r.Agent),
+		TemplateVersion: r.TemplateVersion,
+		Inputs:          r.Inputs,
+		Output:          r.Output,
+		Error:           r.Error,
+		StartedAt:       r.StartedAt,
+		FinishedAt:      r.FinishedAt,
+	}
+	if r.Chat != nil {
+		session.Chat = r.Chat.toDomain()
+	}
+	return session
+}
+
+func cloneAgentSession(session *domain.AgentSession) *domain.AgentSession {
+	clone := *session
+	clone.Inputs = make(map[string]string, len(session.Inputs))
+	for k, v := range session.Inputs {
+		clone.Inputs[k] = v
+	}
+	if session.Chat != nil {
+		clone.Chat = cloneChat(session.Chat)
+	}
+	return &clone
+}
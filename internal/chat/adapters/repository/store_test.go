@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type store interface {
+	application.ChatRepository
+	application.TripStateRepository
+}
+
+func stores(t *testing.T) map[string]store {
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	return map[string]store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+}
+
+func TestChatRepository_RoundTrip(t *testing.T) {
+	for name, repo := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := uuid.New()
+			chat := domain.NewChatWithID(uuid.New(), userID)
+
+			_, err := repo.Load(ctx, chat.ID, userID)
+			assert.ErrorIs(t, err, application.ErrConversationNotFound)
+
+			require.NoError(t, repo.Save(ctx, chat))
+			require.NoError(t, repo.AppendMessages(ctx, chat.ID, userID,
+				domain.NewUserMessage(chat.ID, "I want to go to Peru"),
+				domain.NewAIMessage(chat.ID, "Machu Picchu it is"),
+			))
+			require.NoError(t, repo.AppendMessages(ctx, chat.ID, userID,
+				domain.NewUserMessage(chat.ID, "Make it cheaper"),
+			))
+
+			loaded, err := repo.Load(ctx, chat.ID, userID)
+			require.NoError(t, err)
+			require.Len(t, loaded.Messages, 3)
+			assert.Equal(t, "I want to go to Peru", loaded.Messages[0].Content)
+			assert.Equal(t, domain.SenderAI, loaded.Messages[1].Sender)
+			assert.Equal(t, "Make it cheaper", loaded.Messages[2].Content)
+		})
+	}
+}
+
+func TestChatRepository_RejectsOtherUsers(t *testing.T) {
+	for name, repo := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			chat := domain.NewChatWithID(uuid.New(), uuid.New())
+			require.NoError(t, repo.Save(ctx, chat))
+
+			stranger := uuid.New()
+			_, err := repo.Load(ctx, chat.ID, stranger)
+			assert.ErrorIs(t, err, application.ErrConversationNotOwned)
+
+			err = repo.AppendMessages(ctx, chat.ID, stranger, domain.NewUserMessage(chat.ID, "hi"))
+			assert.ErrorIs(t, err, application.ErrConversationNotOwned)
+
+			assert.ErrorIs(t, repo.Save(ctx, domain.NewChatWithID(chat.ID, stranger)), application.ErrConversationNotOwned)
+		})
+	}
+}
+
+func TestChatRepository_CreateOnlyOnce(t *testing.T) {
+	for name, repo := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := uuid.New()
+			chat := domain.NewChatWithID(uuid.New(), userID)
+			require.NoError(t, repo.Create(ctx, chat))
+			require.NoError(t, repo.AppendMessages(ctx, chat.ID, userID, domain.NewUserMessage(chat.ID, "I want to go to Peru")))
+
+			assert.ErrorIs(t, repo.Create(ctx, domain.NewChatWithID(chat.ID, userID)), application.ErrConversationExists)
+
+			loaded, err := repo.Load(ctx, chat.ID, userID)
+			require.NoError(t, err)
+			assert.Len(t, loaded.Messages, 1, "a second create does not wipe the conversation")
+		})
+	}
+}
+
+func TestTripStateRepository_RoundTrip(t *testing.T) {
+	for name, repo := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := uuid.New()
+			chat := domain.NewChatWithID(uuid.New(), userID)
+
+			state := domain.NewTripState(chat.ID, userID)
+			assert.ErrorIs(t, repo.SaveTripState(ctx, state), application.ErrConversationNotFound)
+
+			require.NoError(t, repo.Save(ctx, chat))
+			_, err := repo.LoadTripState(ctx, chat.ID, userID)
+			assert.ErrorIs(t, err, application.ErrTripStateNotFound)
+
+			state.Intent = domain.TravelIntent{Destinations: []string{"Peru"}, Interest: []string{"hiking"}}
+			state.RecordResult(domain.BudgetPlanner, "cheap|Peru", "~$1,200 USD")
+			clarification := domain.NewClarification([]domain.IntentField{domain.FieldDestinations}, "Which city in Peru?", domain.LocaleEnglish)
+			state.PendingClarification = &clarification
+			blocked := uuid.New()
+			state.BlockedMessages = []uuid.UUID{blocked}
+			state.Locale = domain.LocaleSpanish
+			state.Currency = "EUR"
+			require.NoError(t, repo.SaveTripState(ctx, state))
+
+			loaded, err := repo.LoadTripState(ctx, chat.ID, userID)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Peru"}, loaded.Intent.Destinations)
+			cached, ok := loaded.CachedResult(domain.BudgetPlanner, "cheap|Peru")
+			assert.True(t, ok)
+			assert.Equal(t, "~$1,200 USD", cached)
+			require.NotNil(t, loaded.PendingClarification)
+			assert.Equal(t, []domain.IntentField{domain.FieldDestinations}, loaded.PendingClarification.Fields)
+			assert.Equal(t, "Which city in Peru?", loaded.PendingClarification.Question)
+			assert.True(t, clarification.AskedAt.Equal(loaded.PendingClarification.AskedAt))
+			assert.Equal(t, []uuid.UUID{blocked}, loaded.BlockedMessages)
+			assert.Equal(t, domain.LocaleSpanish, loaded.Locale)
+			assert.Equal(t, domain.Currency("EUR"), loaded.Currency)
+
+			_, err = repo.LoadTripState(ctx, chat.ID, uuid.New())
+			assert.ErrorIs(t, err, application.ErrConversationNotOwned)
+		})
+	}
+}
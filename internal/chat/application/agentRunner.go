@@ -0,0 +1,49 @@
+package application
+
+import (
+	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/domain"
+	"context"
+)
+
+// AgentRunner runs any registered text agent: the system prompt is rendered
+// from the injection, followed by the given chat, and the model's answer is
+// appended to the returned session chat. The session chat is also returned
+// when the model fails, so the messages it was sent can be recorded.
+type AgentRunner struct {
+	client domain.LLMClient
+}
+
+func NewAgentRunner(client domain.LLMClient) *AgentRunner {
+	return &AgentRunner{client: client}
+}
+
+func (u *AgentRunner) Run(
+	ctx context.Context,
+	chat *domain.Chat,
+	injection domain.Injection,
+	model domain.LLMModel,
+	toolbox domain.Toolbox,
+) (*domain.Chat, error) {
+	sessionChat, err := domain.NewAgentSessionFromInjection(injection.Agent.Name, chat.UserID, injection)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sessionChat.AppendMessagesFrom(chat); err != nil {
+		return nil, err
+	}
+
+	session := llm.NewLLMModelSession(u.client, string(model))
+
+	response, err := session.ChatWithTools(ctx, sessionChat.Messages, toolbox)
+	if err != nil {
+		return sessionChat, err
+	}
+
+	if err := sessionChat.AddMessage(response); err != nil {
+		return sessionChat, err
+	}
+
+	return sessionChat, nil
+}
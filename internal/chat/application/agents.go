@@ -0,0 +1,122 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// promptFiles holds the default template of every travel agent, named after the
+// agent, e.g. prompts/destination_expert.tmpl, and its localized templates,
+// e.g. prompts/es/destination_expert.tmpl.
+//
+//go:embed prompts
+var promptFiles embed.FS
+
+// TravelAgents registers the agents of the travel pipeline. To add a specialist,
+// register its definition here and add its node to the pipeline with RunAgent;
+// its template can use the trip details (destination, interest, preferences),
+// the language to answer in and the answers of the agents it depends on, keyed
+// by agent name.
+func TravelAgents() *domain.AgentRegistry {
+	registry := domain.NewAgentRegistry()
+	err := registry.Register(
+		domain.AgentDefinition{
+			Name:         domain.InputGuard,
+			Title:        "LLM 0 (input guard)",
+			Template:     prompt(domain.InputGuard),
+			DefaultModel: "gpt-4o",
+			Output:       domain.OutputStructured,
+			Result:       domain.InjectionAssessment{},
+		},
+		domain.AgentDefinition{
+			Name:         domain.InformationExtractor,
+			Title:        "LLM 1 (extraction)",
+			Template:     prompt(domain.InformationExtractor),
+			OptionalKeys: []string{"destinations", "preferences", "interest", "language"},
+			DefaultModel: "gpt-4o",
+			Output:       domain.OutputStructured,
+			Result:       domain.ExtractedIntent{},
+			Localized:    localizations(domain.InformationExtractor, nil),
+		},
+		domain.AgentDefinition{
+			Name:         domain.DestinationExpert,
+			Title:        "LLM 2 (destination expert)",
+			Template:     prompt(domain.DestinationExpert),
+			RequiredKeys: []string{"destination"},
+			OptionalKeys: []string{"interest", "language"},
+			DefaultModel: "gpt-4",
+			Output:       domain.OutputText,
+			Streaming:    true,
+			Localized:    localizations(domain.DestinationExpert, nil),
+		},
+		domain.AgentDefinition{
+			Name:         domain.BudgetPlanner,
+			Title:        "LLM 3 (budget planner)",
+			Template:     prompt(domain.BudgetPlanner),
+			RequiredKeys: []string{"destination"},
+			OptionalKeys: []string{"preferences", "language"},
+			DefaultModel: "gpt-4o",
+			Output:       domain.OutputStructured,
+			Result:       domain.BudgetEstimate{},
+			Instruction:  "Following your instructions, estimate the cost of my ideal vacation.",
+			Localized: localizations(domain.BudgetPlanner, map[domain.Locale]string{
+				domain.LocaleSpanish: "Siguiendo tus instrucciones, estima el costo de mis vacaciones ideales.",
+			}),
+		},
+		domain.AgentDefinition{
+			Name:         domain.TripSynthesizer,
+			Title:        "LLM 4 (trip synthesizer)",
+			Template:     prompt(domain.TripSynthesizer),
+			RequiredKeys: []string{"suggestions"},
+			OptionalKeys: []string{"caveats", "language"},
+			DefaultModel: "gpt-4",
+			Output:       domain.OutputText,
+			Streaming:    true,
+			Instruction:  "Using the specialists' input in your instructions, give me my best vacation options.",
+			Localized: localizations(domain.TripSynthesizer, map[domain.Locale]string{
+				domain.LocaleSpanish: "Con lo que aportan los especialistas en tus instrucciones, dame mis mejores opciones de vacaciones.",
+			}),
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// prompt returns the embedded template of agent.
+func prompt(agent domain.Agent) string {
+	text, err := promptFiles.ReadFile(fmt.Sprintf("prompts/%s.tmpl", agent))
+	if err != nil {
+		panic(err)
+	}
+	return string(text)
+}
+
+// localizations returns the embedded localized templates of agent, each with
+// its instruction in instructions.
+func localizations(agent domain.Agent, instructions map[domain.Locale]string) map[domain.Locale]domain.Localization {
+	dirs, err := promptFiles.ReadDir("prompts")
+	if err != nil {
+		panic(err)
+	}
+	localized := make(map[domain.Locale]domain.Localization)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		locale := domain.Locale(dir.Name())
+		text, err := promptFiles.ReadFile(fmt.Sprintf("prompts/%s/%s.tmpl", locale, agent))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			panic(err)
+		}
+		localized[locale] = domain.Localization{Template: string(text), Instruction: instructions[locale]}
+	}
+	return localized
+}
@@ -0,0 +1,89 @@
+package application_test
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTravelAgents_RenderTheirTemplates(t *testing.T) {
+	values := map[string]string{
+		"destination":  "Peru, Chile",
+		"destinations": "Peru, Chile",
+		"interest":     "hiking",
+		"preferences":  "mid-range hotels",
+		"suggestions":  "<destination_advice>\nCusco\n</destination_advice>",
+		"caveats":      "None, every specialist answered.",
+		"language":     "French",
+	}
+	phrases := map[domain.Locale]map[domain.Agent][]string{
+		domain.DefaultLocale: {
+			domain.InputGuard:           {guardPhrase},
+			domain.InformationExtractor: {extractionPhrase, "- Destinations: Peru, Chile", "- Interest: hiking", "a short question in French"},
+			domain.DestinationExpert:    {destinationPhrase, "interest in hiking and the list of destinations Peru, Chile", "Write your answer in French."},
+			domain.BudgetPlanner:        {budgetPhrase, "preferences (mid-range hotels)", "booking tips and alternatives in French."},
+			domain.TripSynthesizer:      {synthesisPhrase, "<destination_advice>\nCusco\n</destination_advice>", "None, every specialist answered.", "in French."},
+		},
+		domain.LocaleSpanish: {
+			domain.InputGuard:           {guardPhrase},
+			domain.InformationExtractor: {"extrae los cambios", "- Destinations: Peru, Chile", "una pregunta breve en español"},
+			domain.DestinationExpert:    {"experto local en viajes", "interés del usuario en hiking y la lista de destinos Peru, Chile"},
+			domain.BudgetPlanner:        {"agente de viajes atento a los costos", "preferencias del usuario (mid-range hotels)"},
+			domain.TripSynthesizer:      {"asesor de viajes sénior", "<destination_advice>\nCusco\n</destination_advice>", "Escribe toda la respuesta en español."},
+		},
+	}
+
+	agents := application.TravelAgents()
+	require.ElementsMatch(t, agents.Agents(), []domain.Agent{domain.InputGuard, domain.InformationExtractor, domain.DestinationExpert, domain.BudgetPlanner, domain.TripSynthesizer})
+	for locale, localePhrases := range phrases {
+		for _, agent := range agents.Agents() {
+			t.Run(string(locale)+"/"+string(agent), func(t *testing.T) {
+				registered, _ := agents.Definition(agent)
+				def := registered.Localize(locale)
+				prompt, err := def.Render(values)
+				require.NoError(t, err)
+				for _, phrase := range localePhrases[agent] {
+					assert.Contains(t, prompt, phrase)
+				}
+				assert.NotContains(t, prompt, "{{")
+				assert.NotContains(t, prompt, "<no value>")
+
+				for _, key := range def.RequiredKeys {
+					missing := make(map[string]string, len(values))
+					for k, v := range values {
+						missing[k] = v
+					}
+					missing[key] = ""
+					_, err := def.Render(missing)
+					assert.EqualError(t, err, domain.ErrMissingInjection(key).Error())
+				}
+			})
+		}
+	}
+}
+
+func TestTravelAgents_RenderWithoutOptionalTripDetails(t *testing.T) {
+	values := map[string]string{"destination": `"Peru"`, "destinations": `"Peru"`, "interest": "", "preferences": "", "language": "English"}
+	want := map[domain.Agent]string{
+		domain.InformationExtractor: "- Interest: none yet",
+		domain.DestinationExpert:    `Based on the list of destinations "Peru"`,
+		domain.BudgetPlanner:        `Given the list of destinations "Peru"`,
+	}
+
+	agents := application.TravelAgents()
+	for agent, phrase := range want {
+		def, _ := agents.Definition(agent)
+		prompt, err := def.Render(values)
+		require.NoError(t, err, agent)
+		assert.Contains(t, prompt, phrase)
+		assert.NotContains(t, prompt, "ninguna")
+	}
+
+	def, _ := agents.Definition(domain.DestinationExpert)
+	spanish, err := def.Localize(domain.LocaleSpanish).Render(values)
+	require.NoError(t, err)
+	assert.Contains(t, spanish, `según la lista de destinos "Peru"`)
+}
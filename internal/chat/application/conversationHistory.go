@@ -0,0 +1,38 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ConversationView is a conversation as shown to support staff: the user-facing
+// transcript plus every agent sub-session that produced it.
+type ConversationView struct {
+	Chat     *domain.Chat
+	Sessions []*domain.AgentSession
+}
+
+type ConversationHistory struct {
+	chats    ChatRepository
+	sessions AgentSessionRepository
+}
+
+func NewConversationHistory(chats ChatRepository, sessions AgentSessionRepository) *ConversationHistory {
+	return &ConversationHistory{chats: chats, sessions: sessions}
+}
+
+func (q *ConversationHistory) Get(ctx context.Context, conversationID, userID uuid.UUID) (*ConversationView, error) {
+	chat, err := q.chats.Load(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := q.sessions.ListAgentSessions(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConversationView{Chat: chat, Sessions: sessions}, nil
+}
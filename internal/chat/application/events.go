@@ -0,0 +1,134 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+)
+
+// EventType says what an Event reports; clients dispatch on it.
+type EventType string
+
+const (
+	EventStatus   EventType = "status"   // progress of the run or of an agent
+	EventTool     EventType = "tool"     // an agent called one of its tools
+	EventDegraded EventType = "degraded" // an agent failed and the run went on without it
+	EventError    EventType = "error"    // the run failed
+
+	// EventClarification asks the user for missing trip details; the run stops
+	// and resumes with the user's answer.
+	EventClarification EventType = "clarification"
+	// EventBlocked reports that the user's message was rejected as a prompt
+	// injection; the run stops.
+	EventBlocked EventType = "blocked"
+	// EventVerification reports whether the recommendation only uses the
+	// destinations and prices the specialists supplied.
+	EventVerification EventType = "verification"
+
+	// Delta events carry the next piece of an agent's answer.
+	EventDestinationDelta EventType = "destination.delta"
+	EventBudgetDelta      EventType = "budget.delta"
+	EventSynthesisDelta   EventType = "synthesis.delta"
+	// EventAgentDelta carries the answer of any other agent, named by the
+	// event's Agent.
+	EventAgentDelta EventType = "agent.delta"
+)
+
+// Phase is where the event's agent, or the run itself when there is no agent,
+// is in its lifecycle.
+type Phase string
+
+const (
+	PhaseStarted   Phase = "started"
+	PhaseProgress  Phase = "progress"
+	PhaseCompleted Phase = "completed"
+	PhaseReused    Phase = "reused" // an earlier answer is used instead of calling the agent
+	PhaseRetrying  Phase = "retrying"
+	PhaseFailed    Phase = "failed"
+	PhasePaused    Phase = "paused" // the run waits for the user to answer a clarification
+)
+
+// ErrorCode classifies the failure behind error, degraded and retrying events.
+type ErrorCode string
+
+const (
+	CodeStorage      ErrorCode = "storage_failed" // conversation or trip state could not be loaded or saved
+	CodeAgentFailed  ErrorCode = "agent_failed"
+	CodeAgentTimeout ErrorCode = "agent_timeout"
+	CodeInterrupted  ErrorCode = "interrupted" // the run was cancelled or ran out of time
+	CodeInternal     ErrorCode = "internal"    // any other failure
+)
+
+// Event is something that happened during a run, reported to its client while
+// the run goes on.
+type Event struct {
+	Type  EventType
+	Agent domain.Agent // the agent the event is about, if any
+	Phase Phase
+	Text  string    // a human-readable status, or the next piece of an answer for deltas
+	Code  ErrorCode // set when the event reports a failure
+	// Payload holds structured details: the domain.Degradation of a degraded
+	// event, the domain.ToolCall of a tool event, the domain.Clarification of
+	// a clarification event, the domain.InjectionVerdict of a blocked event,
+	// the domain.GroundingReport of a verification event or the
+	// domain.BudgetPlan of a budget delta.
+	Payload any
+}
+
+// EventSink receives the events of a run. An error means they can no longer be
+// delivered, which cancels the run.
+type EventSink func(Event) error
+
+// deltaEvents names the event the travel agents' answers are sent as, so
+// clients can render every agent in its own panel.
+var deltaEvents = map[domain.Agent]EventType{
+	domain.DestinationExpert: EventDestinationDelta,
+	domain.BudgetPlanner:     EventBudgetDelta,
+	domain.TripSynthesizer:   EventSynthesisDelta,
+}
+
+// deltaEvent returns the event agent's answer is sent as.
+func deltaEvent(agent domain.Agent) EventType {
+	if event, ok := deltaEvents[agent]; ok {
+		return event
+	}
+	return EventAgentDelta
+}
+
+// reusedEvent sends an answer already written, by an earlier turn or before a
+// failed regeneration, in one piece as the agent's delta event.
+func reusedEvent(agent domain.Agent, artifact domain.Artifact) Event {
+	return artifactEvent(agent, PhaseReused, artifact)
+}
+
+// artifactEvent sends an agent's artifact in one piece as its delta event. A
+// budget plan is also its payload.
+func artifactEvent(agent domain.Agent, phase Phase, artifact domain.Artifact) Event {
+	e := Event{Type: deltaEvent(agent), Agent: agent, Phase: phase}
+	if artifact != nil {
+		e.Text = artifact.Content()
+	}
+	if plan, ok := artifact.(domain.BudgetPlan); ok {
+		e.Payload = plan
+	}
+	return e
+}
+
+func statusEvent(agent domain.Agent, phase Phase, text string) Event {
+	return Event{Type: EventStatus, Agent: agent, Phase: phase, Text: text}
+}
+
+func verificationEvent(phase Phase, text string, report domain.GroundingReport) Event {
+	return Event{Type: EventVerification, Agent: domain.GroundingVerifier, Phase: phase, Text: text, Payload: report}
+}
+
+// errorCode classifies an agent failure.
+func errorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeAgentTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeInterrupted
+	}
+	return CodeAgentFailed
+}
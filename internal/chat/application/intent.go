@@ -0,0 +1,14 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"strings"
+)
+
+func joinFields(fields []domain.IntentField) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
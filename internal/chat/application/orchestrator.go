@@ -3,18 +3,85 @@ package application
 import (
 	"acai_travel/internal/chat/domain"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
+// ErrStreamClosed is the cause a run is cancelled with when its events can no
+// longer be delivered, usually because the client went away.
+var ErrStreamClosed = errors.New("event stream closed")
+
+// AgentModels assigns a model alias from the model registry to each agent.
+type AgentModels map[domain.Agent]domain.LLMModel
+
 type MultiAgentOrchestrator struct {
-	service ChatServiceInterface
+	service  ChatServiceInterface
+	chats    ChatRepository
+	states   TripStateRepository
+	sessions AgentSessionRepository
+	models   AgentModels
+	agents   *domain.AgentRegistry
+	tools    *domain.ToolRegistry
+	pipeline *Pipeline
+	rates    domain.ExchangeRateProvider
+}
+
+// NewMultiAgentOrchestrator runs pipeline, or TravelPipeline when nil, with the
+// agents registered in agents, or TravelAgents when nil. Agents without a model
+// in models use their default one. Budgets are converted to the user's currency
+// with rates; without it they are given in USD only.
+func NewMultiAgentOrchestrator(
+	service ChatServiceInterface,
+	chats ChatRepository,
+	states TripStateRepository,
+	sessions AgentSessionRepository,
+	models AgentModels,
+	agents *domain.AgentRegistry,
+	tools *domain.ToolRegistry,
+	pipeline *Pipeline,
+	rates domain.ExchangeRateProvider,
+) *MultiAgentOrchestrator {
+	if agents == nil {
+		agents = TravelAgents()
+	}
+	if pipeline == nil {
+		pipeline = TravelPipeline()
+	}
+	return &MultiAgentOrchestrator{
+		service:  service,
+		chats:    chats,
+		states:   states,
+		sessions: sessions,
+		models:   models,
+		agents:   agents,
+		tools:    tools,
+		pipeline: pipeline,
+		rates:    rates,
+	}
+}
+
+// agent returns the definition of a registered agent, localized for locale.
+func (m *MultiAgentOrchestrator) agent(agent domain.Agent, locale domain.Locale) (domain.AgentDefinition, error) {
+	def, ok := m.agents.Definition(agent)
+	if !ok {
+		return domain.AgentDefinition{}, fmt.Errorf("agent %s is not registered", agent)
+	}
+	return def.Localize(locale), nil
 }
 
-func NewMultiAgentOrchestrator(service ChatServiceInterface) *MultiAgentOrchestrator {
-	return &MultiAgentOrchestrator{service: service}
+// model returns the model assigned to the agent, or its default one.
+func (m *MultiAgentOrchestrator) model(def domain.AgentDefinition) domain.LLMModel {
+	if model, ok := m.models[def.Name]; ok && model != "" {
+		return model
+	}
+	return def.DefaultModel
 }
 
 type OrchestratorInput struct {
@@ -22,158 +89,734 @@ type OrchestratorInput struct {
 	UserID         uuid.UUID
 	Role           string
 	Content        string
+	// Locale, when set, is the language to answer in instead of the one
+	// detected from Content.
+	Locale domain.Locale
+	// Currency, when set, is the currency to give prices in instead of the one
+	// the conversation asked for.
+	Currency domain.Currency
 }
 
 type AgentResponse struct {
-	Result string
-	Error  error
+	Result   string
+	Error    error
+	InputKey string              // identifies the inputs the result was produced from
+	Cached   bool                // true when Result was reused from a previous turn
+	Degraded *domain.Degradation // set when the agent failed and its policy let the run go on
+	Artifact domain.Artifact     // the answer as handed to the agents that depend on this one
+	// Clarification pauses the run: the nodes that did not start yet are skipped
+	// and the question becomes the reply.
+	Clarification *domain.Clarification
+	// Blocked stops the run the same way when the user's message was rejected.
+	Blocked *domain.InjectionVerdict
+}
+
+// halts reports whether the response stops the run.
+func (r AgentResponse) halts() bool {
+	return r.Clarification != nil || r.Blocked != nil
+}
+
+// artifact returns the response's artifact, wrapping a plain result such as a
+// cached fallback in the type agent produces.
+func (r AgentResponse) artifact(agent domain.Agent) domain.Artifact {
+	if r.Artifact != nil {
+		return r.Artifact
+	}
+	if r.Result == "" {
+		return nil
+	}
+	return domain.NewArtifact(agent, r.Result)
+}
+
+// turn carries everything known about the user turn being answered.
+type turn struct {
+	input        OrchestratorInput
+	conversation *domain.Chat
+	userMessage  domain.Message
+	state        *domain.TripState
+	locale       domain.Locale
+	streamFn     EventSink
+
+	mu       sync.Mutex
+	sessions []*domain.AgentSession
+}
+
+// startSession begins the record of an agent call made for this turn.
+func (t *turn) startSession(def domain.AgentDefinition, inputs map[string]string) *domain.AgentSession {
+	session := domain.NewAgentSession(t.conversation.ID, t.userMessage.ID, def.Name, inputs)
+	session.TemplateVersion = def.TemplateVersion
+	t.mu.Lock()
+	t.sessions = append(t.sessions, session)
+	t.mu.Unlock()
+	return session
+}
+
+// finishSession completes a session record. Agents run concurrently, so session
+// records are only written while holding the turn lock.
+func (t *turn) finishSession(session *domain.AgentSession, chat *domain.Chat, output string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	session.Finish(chat, output, err)
 }
 
 func (m *MultiAgentOrchestrator) Run(
 	ctx context.Context,
 	input OrchestratorInput,
-	streamFn func(eventType, data string) error,
+	streamFn EventSink,
 ) error {
-	streamFn("status", "Invoking LLM 1 (extraction)")
+	// Nobody is left to read the answer once an event cannot be delivered, so
+	// the LLM calls still in flight are cancelled instead of paid for.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	streamFn = cancelOnStreamError(streamFn, cancel)
 
-	info, err := m.extractInformation(ctx, input)
+	t, err := m.startTurn(ctx, input)
 	if err != nil {
-		_ = streamFn("error", fmt.Sprintf("LLM 1 failed: %v", err))
-		return fmt.Errorf("LLM 1 failed: %w", err)
+		_ = streamFn(Event{Type: EventError, Phase: PhaseFailed, Code: CodeStorage, Text: fmt.Sprintf("Could not load conversation: %v", err)})
+		return fmt.Errorf("load conversation: %w", err)
 	}
-	streamFn("status", "Got response from LLM 1 (info extracted)")
+	defer m.saveSessions(context.WithoutCancel(ctx), t)
 
-	destinationChan := make(chan AgentResponse)
-	budgetChan := make(chan AgentResponse)
+	state, err := m.loadTripState(ctx, input)
+	if err != nil {
+		_ = streamFn(Event{Type: EventError, Phase: PhaseFailed, Code: CodeStorage, Text: fmt.Sprintf("Could not load trip state: %v", err)})
+		return fmt.Errorf("load trip state: %w", err)
+	}
+	t.state = state
+	t.locale = resolveLocale(input, state)
+	state.Locale = t.locale
+	if input.Currency != "" {
+		state.Currency = input.Currency
+	}
+	t.streamFn = streamFn
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	results, err := m.pipeline.Execute(ctx, m, t)
+	if saveErr := m.saveTripState(context.WithoutCancel(ctx), state, results); saveErr != nil {
+		if err != nil {
+			log.Printf("save trip state for conversation %s: %v", t.conversation.ID, saveErr)
+		} else {
+			_ = streamFn(Event{Type: EventError, Phase: PhaseFailed, Code: CodeStorage, Text: fmt.Sprintf("Could not save trip state: %v", saveErr)})
+			return fmt.Errorf("save trip state: %w", saveErr)
+		}
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			return err
+		}
+		cause := context.Cause(ctx)
+		if !errors.Is(cause, ErrStreamClosed) {
+			_ = streamFn(Event{Type: EventError, Phase: PhaseFailed, Code: CodeInterrupted, Text: fmt.Sprintf("Pipeline interrupted: %v", ctx.Err())})
+		}
+		return fmt.Errorf("pipeline interrupted: %w", cause)
+	}
+
+	answer := results[m.pipeline.Sink()].Result
+	halt := haltingResponse(results)
+	switch {
+	case halt.Blocked != nil:
+		answer = domain.BlockedReply(t.locale)
+	case halt.Clarification != nil:
+		answer = halt.Clarification.Question
+		_ = streamFn(statusEvent("", PhasePaused, "awaiting clarification"))
+	default:
+		_ = streamFn(statusEvent("", PhaseCompleted, "completed"))
+	}
+
+	// The answer is complete, so it is kept even if the client just left.
+	reply := domain.NewAIMessage(t.conversation.ID, answer)
+	if err := m.chats.AppendMessages(context.WithoutCancel(ctx), t.conversation.ID, input.UserID, reply); err != nil {
+		return fmt.Errorf("save reply: %w", err)
+	}
+	return nil
+}
+
+// resolveLocale picks the language of the turn: the one the request asks for,
+// else the one the message is written in, else the conversation's so far.
+func resolveLocale(input OrchestratorInput, state *domain.TripState) domain.Locale {
+	if input.Locale != "" {
+		return input.Locale
+	}
+	if locale, ok := domain.DetectLocale(input.Content); ok {
+		return locale
+	}
+	if state.Locale != "" {
+		return state.Locale
+	}
+	return domain.DefaultLocale
+}
 
-	go func() {
-		defer wg.Done()
-		streamFn("status", "Invoking LLM 2 (destination expert)")
-		destinationChan <- m.runDestinationExpert(ctx, input, info)
-	}()
+// haltingResponse returns the response the run stopped on, if any.
+func haltingResponse(results map[domain.Agent]AgentResponse) AgentResponse {
+	for _, res := range results {
+		if res.halts() {
+			return res
+		}
+	}
+	return AgentResponse{}
+}
 
-	go func() {
-		defer wg.Done()
-		streamFn("status", "Invoking LLM 3 (budget planner)")
-		budgetChan <- m.runBudgetPlanner(ctx, input, info)
-	}()
+// cancelOnStreamError wraps streamFn so that the first event that fails to be
+// delivered cancels the run with ErrStreamClosed as the cause.
+func cancelOnStreamError(streamFn EventSink, cancel context.CancelCauseFunc) EventSink {
+	return func(e Event) error {
+		err := streamFn(e)
+		if err != nil {
+			cancel(fmt.Errorf("%w: %w", ErrStreamClosed, err))
+		}
+		return err
+	}
+}
 
-	var destinationRes, budgetRes AgentResponse
-	wgDone := make(chan struct{})
-	go func() {
-		defer close(wgDone)
-		wg.Wait()
-	}()
+// startTurn loads the conversation identified by input.ConversationID, creating it
+// on first use, and records the incoming user message in it.
+func (m *MultiAgentOrchestrator) startTurn(ctx context.Context, input OrchestratorInput) (*turn, error) {
+	conversation, err := m.loadOrCreate(ctx, input)
+	if err != nil {
+		return nil, err
+	}
 
-	select {
-	case destinationRes = <-destinationChan:
-	case <-ctx.Done():
-		_ = streamFn("error", "Timeout while waiting for destination expert")
-		return ctx.Err()
+	userMsg := domain.NewUserMessage(conversation.ID, input.Content)
+	if err := m.chats.AppendMessages(ctx, conversation.ID, input.UserID, userMsg); err != nil {
+		return nil, err
 	}
+	if err := conversation.AddMessage(userMsg); err != nil {
+		return nil, err
+	}
+	return &turn{input: input, conversation: conversation, userMessage: userMsg}, nil
+}
 
-	select {
-	case budgetRes = <-budgetChan:
-	case <-ctx.Done():
-		_ = streamFn("error", "Timeout while waiting for budget planner")
-		return ctx.Err()
+// loadOrCreate loads the conversation, creating it when this is its first turn.
+// When two first turns race, the one that did not create it loads the other's.
+func (m *MultiAgentOrchestrator) loadOrCreate(ctx context.Context, input OrchestratorInput) (*domain.Chat, error) {
+	conversation, err := m.chats.Load(ctx, input.ConversationID, input.UserID)
+	if !errors.Is(err, ErrConversationNotFound) {
+		return conversation, err
 	}
+	conversation = domain.NewChatWithID(input.ConversationID, input.UserID)
+	err = m.chats.Create(ctx, conversation)
+	if errors.Is(err, ErrConversationExists) {
+		return m.chats.Load(ctx, input.ConversationID, input.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
 
-	if destinationRes.Error != nil {
-		_ = streamFn("error", fmt.Sprintf("LLM 2 failed: %v", destinationRes.Error))
+// saveSessions stores the agent sessions recorded for the turn. It runs even when
+// the turn failed, since failed calls are the ones most worth inspecting.
+func (m *MultiAgentOrchestrator) saveSessions(ctx context.Context, t *turn) {
+	t.mu.Lock()
+	snapshot := make([]domain.AgentSession, 0, len(t.sessions))
+	for _, session := range t.sessions {
+		s := *session
+		if s.FinishedAt.IsZero() {
+			s.Finish(s.Chat, s.Output, errors.New("abandoned before the agent finished"))
+		}
+		snapshot = append(snapshot, s)
 	}
-	if budgetRes.Error != nil {
-		_ = streamFn("error", fmt.Sprintf("LLM 3 failed: %v", budgetRes.Error))
+	t.mu.Unlock()
+
+	for i := range snapshot {
+		if err := m.sessions.SaveAgentSession(ctx, t.input.UserID, &snapshot[i]); err != nil {
+			log.Printf("save agent session %s for conversation %s: %v", snapshot[i].ID, t.conversation.ID, err)
+		}
 	}
+}
 
-	streamFn("status", "Invoking LLM 4 (trip synthesizer)")
-	return m.streamFinalSummary(ctx, input, streamFn, destinationRes.Result, budgetRes.Result)
+// loadTripState returns what was learned in earlier turns of the conversation, or
+// an empty state on the first turn.
+func (m *MultiAgentOrchestrator) loadTripState(ctx context.Context, input OrchestratorInput) (*domain.TripState, error) {
+	state, err := m.states.LoadTripState(ctx, input.ConversationID, input.UserID)
+	if errors.Is(err, ErrTripStateNotFound) {
+		return domain.NewTripState(input.ConversationID, input.UserID), nil
+	}
+	return state, err
 }
 
-func (m *MultiAgentOrchestrator) extractInformation(ctx context.Context, input OrchestratorInput) (map[string]string, error) {
-	chat := domain.NewChat(input.UserID)
-	systemMsg := domain.NewSystemMessage(chat.ID, "Por favor, analiza esta solicitud del usuario. Por favor llena los campos; si no existe alguno, coloca 'ninguna'.")
-	userMsg := domain.NewUserMessage(chat.ID, input.Content)
+// saveTripState remembers the fresh agent answers of the turn so the next turn
+// can reuse them when the agent's inputs did not change.
+func (m *MultiAgentOrchestrator) saveTripState(ctx context.Context, state *domain.TripState, results map[domain.Agent]AgentResponse) error {
+	for agent, res := range results {
+		if res.Error != nil || res.Cached || res.InputKey == "" {
+			continue
+		}
+		state.RecordResult(agent, res.InputKey, res.Result)
+	}
+	return m.states.SaveTripState(ctx, state)
+}
 
-	_ = chat.AddMessage(systemMsg)
-	_ = chat.AddMessage(userMsg)
+// appendHistory copies the conversation history into an agent chat so the agent
+// sees every earlier turn, not just the latest message. Messages the input
+// guard blocked are left out.
+func appendHistory(chat *domain.Chat, t *turn) {
+	for _, m := range t.conversation.History() {
+		if slices.Contains(t.state.BlockedMessages, m.ID) {
+			continue
+		}
+		chat.AddMessage(domain.Message{
+			ID:        uuid.New(),
+			ChatID:    chat.ID,
+			Sender:    m.Sender,
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+		})
+	}
+}
 
-	schema := map[string]any{
-		"type": "object",
-		"properties": map[string]any{
-			"Destinations": map[string]string{"type": "string"},
-			"Preferences":  map[string]string{"type": "string"},
-			"Interest":     map[string]string{"type": "string"},
-		},
-		"required":             []string{"Destinations", "Preferences", "Interest"},
-		"additionalProperties": false,
+// guardInput is the first step: it stops the run when the latest message looks
+// like a prompt injection, checking it against known attacks first and asking
+// the classifier model only when none matched.
+func (m *MultiAgentOrchestrator) guardInput(ctx context.Context, t *turn, _ []NodeResult) (AgentResponse, error) {
+	verdict := domain.DetectInjection(t.userMessage.Content)
+	if verdict == nil {
+		def, err := m.agent(domain.InputGuard, t.locale)
+		if err != nil {
+			return AgentResponse{Error: err}, err
+		}
+		t.streamFn(statusEvent(def.Name, PhaseStarted, fmt.Sprintf("Invoking %s", def.Title)))
+		assessment, err := m.classifyInput(ctx, t, def)
+		if err != nil {
+			return AgentResponse{Error: err}, fmt.Errorf("%s failed: %w", def.Title, err)
+		}
+		verdict = assessment.Verdict()
+	}
+	if verdict == nil {
+		t.streamFn(statusEvent(domain.InputGuard, PhaseCompleted, "Message accepted"))
+		return AgentResponse{}, nil
 	}
 
-	return m.service.InformationExtraction(ctx, chat, schema, "gpt-4o")
+	t.state.BlockedMessages = append(t.state.BlockedMessages, t.userMessage.ID)
+	t.streamFn(Event{
+		Type:    EventBlocked,
+		Agent:   domain.InputGuard,
+		Phase:   PhaseFailed,
+		Text:    fmt.Sprintf("Message blocked: %s", verdict.Reason),
+		Payload: *verdict,
+	})
+	return AgentResponse{Result: string(verdict.Reason), Blocked: verdict}, nil
+}
+
+// classifyInput shows the classifier only the latest message, so earlier turns
+// cannot steer its verdict.
+func (m *MultiAgentOrchestrator) classifyInput(ctx context.Context, t *turn, def domain.AgentDefinition) (domain.InjectionAssessment, error) {
+	injection := domain.Injection{Agent: def, Values: map[string]string{}}
+	chat := domain.NewChat(t.input.UserID)
+	chat.AddMessage(domain.NewUserMessage(chat.ID, t.userMessage.Content))
+	return runStructured[domain.InjectionAssessment](ctx, m, t, injection, map[string]string{"message": t.userMessage.Content}, chat)
 }
 
-func (m *MultiAgentOrchestrator) runDestinationExpert(ctx context.Context, input OrchestratorInput, info map[string]string) AgentResponse {
-	chat := domain.NewChat(input.UserID)
-	chat.AddMessage(domain.NewUserMessage(chat.ID, input.Content))
+// runStructured runs a structured agent on chat and returns its answer, which
+// is a T when T is the type of the definition's Result. The session record
+// gets inputs and the answer as JSON.
+func runStructured[T any](ctx context.Context, m *MultiAgentOrchestrator, t *turn, injection domain.Injection, inputs map[string]string, chat *domain.Chat) (T, error) {
+	def := injection.Agent
+	session := t.startSession(def, inputs)
 
-	injection := domain.DestinationExpertInjection{
-		Interest:    info["Interest"],
-		Destination: info["Destinations"],
+	resp, result, err := m.service.RunStructured(ctx, chat, injection, m.model(def))
+	out, ok := result.(T)
+	if err == nil && !ok {
+		err = fmt.Errorf("%s answered with %T, want %T", def.Title, result, out)
 	}
+	output, _ := json.Marshal(out)
+	t.finishSession(session, sessionChat(resp, chat), string(output), err)
+	return out, err
+}
 
-	resp, err := m.service.GetDestinationAdvice(ctx, chat, injection, "gpt-4")
+// extractIntent is the extraction step: it folds the trip details introduced by
+// the latest message into the trip state read by the other agents.
+func (m *MultiAgentOrchestrator) extractIntent(ctx context.Context, t *turn, _ []NodeResult) (AgentResponse, error) {
+	def, err := m.agent(domain.InformationExtractor, t.locale)
 	if err != nil {
-		return AgentResponse{"No destination advice available.", err}
+		return AgentResponse{Error: err}, err
 	}
-	if len(resp.Messages) == 0 {
-		return AgentResponse{"No destination advice available.", fmt.Errorf("empty response")}
+	t.streamFn(statusEvent(def.Name, PhaseStarted, fmt.Sprintf("Invoking %s", def.Title)))
+
+	info, err := m.extractInformation(ctx, t, def)
+	if err != nil {
+		return AgentResponse{Error: err}, fmt.Errorf("%s failed: %w", def.Title, err)
+	}
+	t.streamFn(statusEvent(def.Name, PhaseCompleted, fmt.Sprintf("Got response from %s", def.Title)))
+
+	intent, changed := t.state.Intent.Apply(info.Delta())
+	t.state.Intent = intent
+	if len(changed) > 0 {
+		t.streamFn(statusEvent(domain.InformationExtractor, PhaseProgress, fmt.Sprintf("Trip details updated: %s", joinFields(changed))))
+	}
+	// A currency given with the request wins over one named in the message.
+	if currency, ok := domain.ParseCurrency(info.Currency); ok && t.input.Currency == "" && currency != t.state.Currency {
+		t.state.Currency = currency
+		t.streamFn(statusEvent(domain.InformationExtractor, PhaseProgress, fmt.Sprintf("Prices will be given in %s", currency)))
+	}
+
+	// Recommending places for a destination the user never gave is worse than
+	// asking for it, so the run stops here until the user answers.
+	if unclear := intent.UnclearFields(info); len(unclear) > 0 {
+		clarification := domain.NewClarification(unclear, info.FollowUp, t.locale)
+		t.state.PendingClarification = &clarification
+		t.streamFn(Event{
+			Type:    EventClarification,
+			Agent:   domain.InformationExtractor,
+			Phase:   PhasePaused,
+			Text:    clarification.Question,
+			Payload: clarification,
+		})
+		return AgentResponse{Result: joinFields(changed), Clarification: &clarification}, nil
+	}
+	if t.state.PendingClarification != nil {
+		t.state.PendingClarification = nil
+		t.streamFn(statusEvent(domain.InformationExtractor, PhaseProgress, "Clarification answered, resuming"))
+	}
+	return AgentResponse{Result: joinFields(changed)}, nil
+}
+
+func (m *MultiAgentOrchestrator) extractInformation(ctx context.Context, t *turn, def domain.AgentDefinition) (domain.ExtractedIntent, error) {
+	known := t.state.Intent
+	injection := domain.Injection{Agent: def, Values: map[string]string{
+		"destinations": known.Text(domain.FieldDestinations),
+		"preferences":  known.Text(domain.FieldPreferences),
+		"interest":     known.Text(domain.FieldInterest),
+		"language":     t.locale.Language(),
+	}}
+	chat := domain.NewChat(t.input.UserID)
+	appendHistory(chat, t)
+	return runStructured[domain.ExtractedIntent](ctx, m, t, injection, injection.Inputs(), chat)
+}
+
+// RunAgent is the pipeline step of a registered text agent. Its template is
+// filled from the trip details (destination, interest and preferences) and
+// from the answers of the nodes it depends on, keyed by agent name. An answer
+// produced from the same values in an earlier turn is reused.
+func RunAgent(agent domain.Agent) StepFunc {
+	return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		return m.runAgent(ctx, t, agent, deps)
+	}
+}
+
+func (m *MultiAgentOrchestrator) runAgent(ctx context.Context, t *turn, agent domain.Agent, deps []NodeResult) (AgentResponse, error) {
+	def, err := m.agent(agent, t.locale)
+	if err != nil {
+		return AgentResponse{Error: err}, err
+	}
+	injection := domain.Injection{Agent: def, Values: agentValues(def, t.state.Intent, t.locale, deps)}
+	inputKey := def.InputKey(m.model(def), injection.Values)
+
+	if res, ok := reuseResult(t, def, inputKey); ok {
+		return res, nil
+	}
+
+	t.streamFn(statusEvent(agent, PhaseStarted, fmt.Sprintf("Invoking %s", def.Title)))
+
+	chat := agentChat(def, t)
+	session := t.startSession(def, injection.Inputs())
+	resp, err := m.service.RunAgent(ctx, chat, injection, m.model(def), m.toolbox(def, t.streamFn))
+	res := agentResponse(resp, err)
+	res.InputKey = inputKey
+	t.finishSession(session, sessionChat(resp, chat), res.Result, res.Error)
+	if res.Error != nil {
+		return res, fmt.Errorf("%s failed: %w", def.Title, res.Error)
 	}
-	return AgentResponse{resp.Messages[len(resp.Messages)-1].Content, nil}
+	res.Artifact = domain.NewArtifact(agent, res.Result)
+	if !def.Streaming {
+		t.streamFn(Event{Type: deltaEvent(agent), Agent: agent, Phase: PhaseCompleted, Text: res.Result})
+	}
+	return res, nil
+}
+
+// agentChat is what an agent sees after its system prompt: its instruction
+// when it has one, the conversation history otherwise.
+func agentChat(def domain.AgentDefinition, t *turn) *domain.Chat {
+	chat := domain.NewChat(t.input.UserID)
+	if def.Instruction != "" {
+		chat.AddMessage(domain.NewUserMessage(chat.ID, def.Instruction))
+	} else {
+		appendHistory(chat, t)
+	}
+	return chat
+}
+
+// reuseResult returns the agent's answer from an earlier turn when it was
+// produced from the same inputs, and sends it as the agent's delta event.
+func reuseResult(t *turn, def domain.AgentDefinition, inputKey string) (AgentResponse, bool) {
+	cached, ok := t.state.CachedResult(def.Name, inputKey)
+	if !ok {
+		return AgentResponse{}, false
+	}
+	artifact := domain.NewArtifact(def.Name, cached)
+	t.streamFn(statusEvent(def.Name, PhaseReused, fmt.Sprintf("Reusing %s answer (inputs unchanged)", def.Title)))
+	t.streamFn(reusedEvent(def.Name, artifact))
+	return AgentResponse{Result: cached, InputKey: inputKey, Cached: true, Artifact: artifact}, true
 }
 
-func (m *MultiAgentOrchestrator) runBudgetPlanner(ctx context.Context, input OrchestratorInput, info map[string]string) AgentResponse {
-	chat := domain.NewChat(input.UserID)
-	chat.AddMessage(domain.NewUserMessage(chat.ID, "Dadas tus instrucciones responde con mis vacaciones perferctas"))
+// PlanBudget is the budget planner's step. The model gives the line items of
+// each destination's budget as structured output; the totals are added up in
+// Go and each destination gets its budget category from rules, so the
+// synthesizer gets validated numbers.
+func PlanBudget(rules domain.BudgetRules) StepFunc {
+	return RunStructuredAgent(domain.BudgetPlanner, func(estimate domain.BudgetEstimate) (domain.Artifact, error) {
+		return domain.NewBudgetPlan(estimate, rules)
+	})
+}
 
-	injection := domain.BudgetPlannerInjection{
-		Preferences: info["Preferences"],
-		Destination: info["Destinations"],
+// RunStructuredAgent is the pipeline step of a registered structured agent
+// whose Result is a T. Its template is filled like RunAgent's, and build turns
+// the answer into the agent's artifact, which is sent in one piece as the
+// agent's delta event. An artifact produced from the same values in an
+// earlier turn is reused.
+func RunStructuredAgent[T any](agent domain.Agent, build func(T) (domain.Artifact, error)) StepFunc {
+	return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		return runStructuredAgent(ctx, m, t, agent, build, deps)
+	}
+}
+
+func runStructuredAgent[T any](ctx context.Context, m *MultiAgentOrchestrator, t *turn, agent domain.Agent, build func(T) (domain.Artifact, error), deps []NodeResult) (AgentResponse, error) {
+	def, err := m.agent(agent, t.locale)
+	if err != nil {
+		return AgentResponse{Error: err}, err
+	}
+	injection := domain.Injection{Agent: def, Values: agentValues(def, t.state.Intent, t.locale, deps)}
+	inputKey := def.InputKey(m.model(def), injection.Values)
+	if res, ok := reuseResult(t, def, inputKey); ok {
+		return res, nil
+	}
+
+	t.streamFn(statusEvent(agent, PhaseStarted, fmt.Sprintf("Invoking %s", def.Title)))
+	answer, err := runStructured[T](ctx, m, t, injection, injection.Inputs(), agentChat(def, t))
+	if err != nil {
+		return AgentResponse{Error: err, InputKey: inputKey}, fmt.Errorf("%s failed: %w", def.Title, err)
+	}
+	artifact, err := build(answer)
+	if err != nil {
+		return AgentResponse{Error: err, InputKey: inputKey}, fmt.Errorf("%s failed: %w", def.Title, err)
+	}
+	t.streamFn(statusEvent(agent, PhaseCompleted, fmt.Sprintf("Got response from %s", def.Title)))
+	t.streamFn(artifactEvent(agent, PhaseCompleted, artifact))
+
+	output, _ := json.Marshal(artifact)
+	return AgentResponse{Result: string(output), InputKey: inputKey, Artifact: artifact}, nil
+}
+
+// agentValues collects the values of the placeholders def's template uses.
+func agentValues(def domain.AgentDefinition, intent domain.TravelIntent, locale domain.Locale, deps []NodeResult) map[string]string {
+	// The trip details come from the user, so they are quoted and capped.
+	available := map[string]string{
+		"destination":  intent.Text(domain.FieldDestinations),
+		"destinations": intent.Text(domain.FieldDestinations),
+		"interest":     intent.Text(domain.FieldInterest),
+		"preferences":  intent.Text(domain.FieldPreferences),
+		"language":     locale.Language(),
+	}
+	for _, dep := range deps {
+		if artifact := dep.Response.artifact(dep.Agent); artifact != nil {
+			available[string(dep.Agent)] = artifact.Content()
+		}
+	}
+
+	values := make(map[string]string)
+	for _, key := range def.Keys() {
+		values[key] = available[key]
+	}
+	return values
+}
+
+// toolbox offers the agent its registered tools, reports each call to the user
+// as a "tool" event and, for streaming agents, streams the answer as the
+// agent's delta event.
+func (m *MultiAgentOrchestrator) toolbox(def domain.AgentDefinition, streamFn EventSink) domain.Toolbox {
+	agent := def.Name
+	var stream func(chunk string) error
+	if def.Streaming {
+		stream = func(chunk string) error {
+			return streamFn(Event{Type: deltaEvent(agent), Agent: agent, Phase: PhaseProgress, Text: chunk})
+		}
+	}
+	return domain.Toolbox{
+		Stream: stream,
+		Tools:  m.tools.For(agent),
+		Observe: func(tool domain.Tool, call domain.ToolCall) {
+			status := tool.Status
+			if status == "" {
+				status = fmt.Sprintf("Calling %s", tool.Name)
+			}
+			_ = streamFn(Event{Type: EventTool, Agent: agent, Phase: PhaseProgress, Text: fmt.Sprintf("%s: %s", agent, status), Payload: call})
+		},
 	}
+}
 
-	resp, err := m.service.PlanBudget(ctx, chat, injection, "gpt-4")
+// agentResponse turns an agent session into the answer handed to the next stage.
+func agentResponse(resp *domain.Chat, err error) AgentResponse {
 	if err != nil {
-		return AgentResponse{"No budget plan available.", err}
+		return AgentResponse{Error: err}
 	}
 	if len(resp.Messages) == 0 {
-		return AgentResponse{"No budget plan available.", fmt.Errorf("empty response")}
+		return AgentResponse{Error: fmt.Errorf("empty response")}
 	}
-	return AgentResponse{resp.Messages[len(resp.Messages)-1].Content, nil}
+	return AgentResponse{Result: resp.Messages[len(resp.Messages)-1].Content}
 }
 
-func (m *MultiAgentOrchestrator) streamFinalSummary(
-	ctx context.Context,
-	input OrchestratorInput,
-	streamFn func(eventType, data string) error,
-	destination, budget string,
-) error {
-	chat := domain.NewChat(input.UserID)
-	chat.AddMessage(domain.NewUserMessage(chat.ID, budget))
-	chat.AddMessage(domain.NewUserMessage(chat.ID, destination))
-	chat.AddMessage(domain.NewUserMessage(chat.ID, "Given this messages pelase give me my best vacations"))
+// sessionChat prefers the agent's own session, which includes the rendered system
+// prompt, and falls back to the chat that was sent when the agent failed early.
+func sessionChat(resp, sent *domain.Chat) *domain.Chat {
+	if resp != nil {
+		return resp
+	}
+	return sent
+}
+
+// noCaveats is what the synthesizer is told when every agent answered.
+const noCaveats = "None, every specialist answered."
+
+// synthesize streams the trip summary built from the artifacts of the agents
+// it depends on.
+func (m *MultiAgentOrchestrator) synthesize(ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+	return m.runSynthesizer(ctx, t, deps, "")
+}
 
-	injections := domain.TripSynthesizerInjection{
-		Suggestions: "Follow very closely toy instructions, Used all information provided by the user",
+// runSynthesizer runs the synthesizer on the artifacts of deps. A correction is
+// added to the limitations of its input.
+func (m *MultiAgentOrchestrator) runSynthesizer(ctx context.Context, t *turn, deps []NodeResult, correction string) (AgentResponse, error) {
+	def, err := m.agent(domain.TripSynthesizer, t.locale)
+	if err != nil {
+		return AgentResponse{Error: err}, err
+	}
+	t.streamFn(statusEvent(def.Name, PhaseStarted, fmt.Sprintf("Invoking %s", def.Title)))
+
+	artifacts, caveats := dependencyArtifacts(deps)
+	rate, caveat := m.convertBudgets(ctx, t, artifacts)
+	switch {
+	case caveat != "":
+		caveats = append(caveats, "- "+caveat)
+	case rate != nil:
+		t.streamFn(statusEvent(domain.TripSynthesizer, PhaseProgress, fmt.Sprintf("Converted prices to %s at %s", rate.To, rate)))
+	}
+	if correction != "" {
+		caveats = append(caveats, "- "+correction)
+	}
+	injection := domain.Injection{Agent: def, Values: map[string]string{
+		"suggestions": domain.RenderArtifacts(artifacts),
+		"caveats":     noCaveats,
+		"language":    t.locale.Language(),
+	}}
+	if len(caveats) > 0 {
+		injection.Values["caveats"] = strings.Join(caveats, "\n")
 	}
+	chat := domain.NewChat(t.input.UserID)
+	chat.AddMessage(domain.NewUserMessage(chat.ID, def.Instruction))
 
-	err := m.service.StreamTripSummary(ctx, chat, injections, "gpt-4", streamFn)
+	session := t.startSession(def, injection.Inputs())
+	resp, err := m.service.RunAgent(ctx, chat, injection, m.model(def), m.toolbox(def, t.streamFn))
+	res := agentResponse(resp, err)
+	t.finishSession(session, sessionChat(resp, chat), res.Result, res.Error)
+	if res.Error != nil {
+		return res, fmt.Errorf("%s failed: %w", def.Title, res.Error)
+	}
+	return res, nil
+}
+
+// convertBudgets converts the budget plans among artifacts, in place, to the
+// currency of the trip state. It returns the rate they were converted at, if
+// any, or a caveat for the synthesizer when the amounts cannot be converted.
+func (m *MultiAgentOrchestrator) convertBudgets(ctx context.Context, t *turn, artifacts []domain.Artifact) (*domain.ExchangeRate, string) {
+	currency := t.state.Currency
+	if currency == "" || currency == domain.CurrencyUSD {
+		return nil, ""
+	}
+	var plans []int
+	for i, artifact := range artifacts {
+		if _, ok := artifact.(domain.BudgetPlan); ok {
+			plans = append(plans, i)
+		}
+	}
+	if len(plans) == 0 {
+		return nil, ""
+	}
+
+	err := errors.New("no exchange-rate provider")
+	var rate domain.ExchangeRate
+	if m.rates != nil {
+		rate, err = m.rates.Rate(ctx, domain.CurrencyUSD, currency)
+	}
 	if err != nil {
-		_ = streamFn("error", fmt.Sprintf("LLM 4 failed: %v", err))
-		return fmt.Errorf("LLM 4 failed: %w", err)
+		log.Printf("convert budget to %s for conversation %s: %v", currency, t.conversation.ID, err)
+		return nil, fmt.Sprintf("Prices could not be converted to %s; give them in USD only.", currency)
+	}
+	for _, i := range plans {
+		artifacts[i] = artifacts[i].(domain.BudgetPlan).Convert(rate)
+	}
+	return &rate, ""
+}
+
+// dependencyArtifacts collects the artifacts of deps and a caveat for each of
+// them that degraded.
+func dependencyArtifacts(deps []NodeResult) ([]domain.Artifact, []string) {
+	var artifacts []domain.Artifact
+	var caveats []string
+	for _, dep := range deps {
+		if dep.Response.Degraded != nil {
+			caveats = append(caveats, "- "+dep.Response.Degraded.Caveat())
+		}
+		if artifact := dep.Response.artifact(dep.Agent); artifact != nil {
+			artifacts = append(artifacts, artifact)
+		}
 	}
+	return artifacts, caveats
+}
 
-	_ = streamFn("status", "completed")
-	return nil
+// VerifyGrounding returns the step of the grounding verifier, which depends on
+// the synthesizer and on the specialists the synthesizer depends on. It checks
+// that the synthesizer's answer only uses the destinations and prices of the
+// specialists' artifacts, converted to the user's currency as the synthesizer
+// saw them, and reports the result as a verification event. An answer that is
+// not grounded is kept and flagged, or with GroundingRegenerate first
+// synthesized once more, told what to drop. When that fails, the first answer
+// is sent again in one piece to replace what the failed attempt streamed.
+func VerifyGrounding(onIssues domain.GroundingAction) StepFunc {
+	return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		return m.verifyGrounding(ctx, t, deps, onIssues)
+	}
+}
+
+func (m *MultiAgentOrchestrator) verifyGrounding(ctx context.Context, t *turn, deps []NodeResult, onIssues domain.GroundingAction) (AgentResponse, error) {
+	var answer string
+	var synthesized bool
+	var specialists []NodeResult
+	for _, dep := range deps {
+		if dep.Agent == domain.TripSynthesizer {
+			answer, synthesized = dep.Response.Result, true
+			continue
+		}
+		specialists = append(specialists, dep)
+	}
+	if !synthesized {
+		err := fmt.Errorf("%s does not depend on %s", domain.GroundingVerifier, domain.TripSynthesizer)
+		return AgentResponse{Error: err}, err
+	}
+
+	artifacts, _ := dependencyArtifacts(specialists)
+	m.convertBudgets(ctx, t, artifacts)
+	destinations := t.state.Intent.Values(domain.FieldDestinations)
+	report := domain.CheckGrounding(answer, artifacts, destinations, t.locale)
+
+	if !report.Grounded() && onIssues == domain.GroundingRegenerate {
+		t.streamFn(verificationEvent(PhaseRetrying, fmt.Sprintf("Recommendation uses %d destinations or prices the specialists did not supply, regenerating it", len(report.Issues)), report))
+		res, err := m.runSynthesizer(ctx, t, specialists, report.Correction())
+		switch {
+		case err == nil:
+			answer = res.Result
+			report = domain.CheckGrounding(answer, artifacts, destinations, t.locale)
+		case ctx.Err() != nil:
+			return res, err
+		default:
+			log.Printf("regenerate recommendation for conversation %s: %v", t.conversation.ID, err)
+			t.streamFn(statusEvent(domain.GroundingVerifier, PhaseProgress, "Could not regenerate the recommendation, keeping the first one"))
+			t.streamFn(reusedEvent(domain.TripSynthesizer, domain.NewArtifact(domain.TripSynthesizer, answer)))
+		}
+	}
+
+	if report.Grounded() {
+		t.streamFn(verificationEvent(PhaseCompleted, "Recommendation only uses the specialists' destinations and prices", report))
+	} else {
+		t.streamFn(verificationEvent(PhaseCompleted, fmt.Sprintf("Recommendation uses %d destinations or prices the specialists did not supply", len(report.Issues)), report))
+	}
+	return AgentResponse{Result: answer}, nil
 }
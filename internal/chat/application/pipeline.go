@@ -0,0 +1,395 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StepFunc runs one agent of the pipeline. deps holds the responses of the
+// node's dependencies in the order they were declared. What a returned error
+// does to the run is up to the node's failure policy.
+type StepFunc func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error)
+
+// Node is an agent in the pipeline and the agents whose answers it needs.
+type Node struct {
+	Agent     domain.Agent
+	DependsOn []domain.Agent
+	Step      StepFunc
+	Policy    domain.AgentPolicy
+	// Required nodes never let the run go on without their answer: a failure
+	// that outlasts their policy fails the run.
+	Required bool
+}
+
+// NodeResult is the response of a finished node.
+type NodeResult struct {
+	Agent    domain.Agent
+	Response AgentResponse
+}
+
+// Pipeline is a validated DAG of agent nodes. Each node starts as soon as all of
+// its dependencies finished, so independent agents run in parallel.
+type Pipeline struct {
+	nodes []Node
+	sink  domain.Agent
+}
+
+// PipelineBuilder declares a Pipeline node by node. Problems are reported by Build.
+type PipelineBuilder struct {
+	nodes []Node
+}
+
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// Node adds an agent that runs step once every agent in dependsOn finished. A
+// failing node fails the run unless Policy says otherwise.
+func (b *PipelineBuilder) Node(agent domain.Agent, step StepFunc, dependsOn ...domain.Agent) *PipelineBuilder {
+	b.nodes = append(b.nodes, Node{
+		Agent:     agent,
+		DependsOn: dependsOn,
+		Step:      step,
+		Policy:    domain.AgentPolicy{OnFailure: domain.PolicyFail},
+	})
+	return b
+}
+
+// Policy sets the deadline and failure policy of an agent added with Node.
+func (b *PipelineBuilder) Policy(agent domain.Agent, policy domain.AgentPolicy) *PipelineBuilder {
+	for i := range b.nodes {
+		if b.nodes[i].Agent == agent {
+			b.nodes[i].Policy = policy
+			return b
+		}
+	}
+	b.nodes = append(b.nodes, Node{Agent: agent, Policy: policy})
+	return b
+}
+
+// Require marks agents added with Node as required. Their policy may only be
+// fail or retry; a retry that fails too fails the run.
+func (b *PipelineBuilder) Require(agents ...domain.Agent) *PipelineBuilder {
+	for _, agent := range agents {
+		found := false
+		for i := range b.nodes {
+			if b.nodes[i].Agent == agent {
+				b.nodes[i].Required = true
+				found = true
+			}
+		}
+		if !found {
+			b.nodes = append(b.nodes, Node{Agent: agent, Policy: domain.AgentPolicy{OnFailure: domain.PolicyFail}, Required: true})
+		}
+	}
+	return b
+}
+
+// Build checks that the nodes form a DAG with a single final node, whose answer
+// becomes the reply to the user.
+func (b *PipelineBuilder) Build() (*Pipeline, error) {
+	if len(b.nodes) == 0 {
+		return nil, errors.New("pipeline: no nodes")
+	}
+
+	byAgent := make(map[domain.Agent]Node, len(b.nodes))
+	for _, node := range b.nodes {
+		if node.Step == nil {
+			return nil, fmt.Errorf("pipeline: %s has no step", node.Agent)
+		}
+		if err := node.Policy.Validate(); err != nil {
+			return nil, fmt.Errorf("pipeline: %s: %w", node.Agent, err)
+		}
+		if node.Required && node.Policy.OnFailure.Degrades() {
+			return nil, fmt.Errorf("pipeline: %s is required, so its failure policy must be fail or retry, got %s", node.Agent, node.Policy.OnFailure)
+		}
+		if _, dup := byAgent[node.Agent]; dup {
+			return nil, fmt.Errorf("pipeline: duplicate node %s", node.Agent)
+		}
+		byAgent[node.Agent] = node
+	}
+
+	dependents := make(map[domain.Agent]int, len(b.nodes))
+	for _, node := range b.nodes {
+		for _, dep := range node.DependsOn {
+			if _, ok := byAgent[dep]; !ok {
+				return nil, fmt.Errorf("pipeline: %s depends on unknown node %s", node.Agent, dep)
+			}
+			dependents[dep]++
+		}
+	}
+
+	if cycle := findCycle(b.nodes, byAgent); cycle != nil {
+		return nil, fmt.Errorf("pipeline: dependency cycle %s", joinAgents(cycle, " -> "))
+	}
+
+	var sinks []domain.Agent
+	for _, node := range b.nodes {
+		if dependents[node.Agent] == 0 {
+			sinks = append(sinks, node.Agent)
+		}
+	}
+	if len(sinks) != 1 {
+		return nil, fmt.Errorf("pipeline: want exactly one final node, got %s", joinAgents(sinks, ", "))
+	}
+
+	return &Pipeline{nodes: append([]Node(nil), b.nodes...), sink: sinks[0]}, nil
+}
+
+// MustBuild is Build for pipelines defined in code, where an invalid definition
+// is a programming error.
+func (b *PipelineBuilder) MustBuild() *Pipeline {
+	p, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Sink returns the final node, whose answer is the reply to the user.
+func (p *Pipeline) Sink() domain.Agent {
+	return p.sink
+}
+
+// WithPolicies returns a copy of the pipeline with the given agents' policies
+// replaced, e.g. by the ones from the model configuration. A policy without
+// OnFailure keeps the node's current one.
+func (p *Pipeline) WithPolicies(policies map[domain.Agent]domain.AgentPolicy) (*Pipeline, error) {
+	b := &PipelineBuilder{nodes: p.Nodes()}
+	for agent, policy := range policies {
+		node, ok := p.node(agent)
+		if !ok {
+			return nil, fmt.Errorf("pipeline: policy for unknown node %s", agent)
+		}
+		if policy.OnFailure == "" {
+			policy.OnFailure = node.Policy.OnFailure
+		}
+		b.Policy(agent, policy)
+	}
+	return b.Build()
+}
+
+// WithStep returns a copy of the pipeline where agent runs step instead, e.g.
+// PlanBudget with configured budget rules.
+func (p *Pipeline) WithStep(agent domain.Agent, step StepFunc) (*Pipeline, error) {
+	nodes := p.Nodes()
+	for i := range nodes {
+		if nodes[i].Agent == agent {
+			nodes[i].Step = step
+			return (&PipelineBuilder{nodes: nodes}).Build()
+		}
+	}
+	return nil, fmt.Errorf("pipeline: step for unknown node %s", agent)
+}
+
+func (p *Pipeline) node(agent domain.Agent) (Node, bool) {
+	for _, node := range p.nodes {
+		if node.Agent == agent {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// Nodes returns the pipeline's nodes in declaration order.
+func (p *Pipeline) Nodes() []Node {
+	return append([]Node(nil), p.nodes...)
+}
+
+// Execute runs every node once its dependencies finished. Failed nodes are
+// handled by their policy; the first failure that fails the run cancels the
+// nodes still running and is returned along with the responses of the nodes
+// that did finish. A response asking for a clarification or blocking the
+// user's message stops the run the same way, but without an error.
+func (p *Pipeline) Execute(ctx context.Context, m *MultiAgentOrchestrator, t *turn) (map[domain.Agent]AgentResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(map[domain.Agent]chan struct{}, len(p.nodes))
+	for _, node := range p.nodes {
+		done[node.Agent] = make(chan struct{})
+	}
+
+	var (
+		mu       sync.Mutex
+		results  = make(map[domain.Agent]AgentResponse, len(p.nodes))
+		firstErr error
+		paused   bool
+		wg       sync.WaitGroup
+	)
+
+	for _, node := range p.nodes {
+		wg.Add(1)
+		go func(node Node) {
+			defer wg.Done()
+
+			for _, dep := range node.DependsOn {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			mu.Lock()
+			deps := make([]NodeResult, 0, len(node.DependsOn))
+			for _, dep := range node.DependsOn {
+				deps = append(deps, NodeResult{Agent: dep, Response: results[dep]})
+			}
+			mu.Unlock()
+
+			resp, err := runNode(ctx, m, t, node, deps)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if paused {
+				// Whatever the node did after the pause was cancelled anyway.
+				return
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				cancel()
+				return
+			}
+			results[node.Agent] = resp
+			if resp.halts() && firstErr == nil {
+				paused = true
+				cancel()
+				return
+			}
+			close(done[node.Agent])
+		}(node)
+	}
+	wg.Wait()
+
+	if paused {
+		return results, nil
+	}
+	if firstErr == nil && len(results) < len(p.nodes) {
+		firstErr = ctx.Err()
+	}
+	return results, firstErr
+}
+
+// runNode runs a node within its deadline and applies its failure policy. A
+// failure caused by the run itself being cancelled is returned as is, and so is
+// the failed retry of a required node.
+func runNode(ctx context.Context, m *MultiAgentOrchestrator, t *turn, node Node, deps []NodeResult) (AgentResponse, error) {
+	resp, err := runStep(ctx, m, t, node, deps)
+	if err == nil || ctx.Err() != nil {
+		return resp, err
+	}
+
+	policy := node.Policy.OnFailure
+	if policy == domain.PolicyRetry {
+		_ = t.streamFn(Event{
+			Type:  EventStatus,
+			Agent: node.Agent,
+			Phase: PhaseRetrying,
+			Code:  errorCode(err),
+			Text:  fmt.Sprintf("Retrying %s after error: %v", node.Agent, err),
+		})
+		resp, err = runStep(ctx, m, t, node, deps)
+		if err == nil || ctx.Err() != nil {
+			return resp, err
+		}
+	}
+	if policy == domain.PolicyFail || node.Required {
+		_ = t.streamFn(Event{Type: EventError, Agent: node.Agent, Phase: PhaseFailed, Code: errorCode(err), Text: err.Error()})
+		return resp, err
+	}
+
+	degradation := domain.Degradation{Agent: node.Agent, Policy: policy, Outcome: domain.OutcomeOmitted, Reason: err.Error()}
+	resp = AgentResponse{Error: err, Degraded: &degradation}
+	if policy == domain.PolicyCached {
+		if cached, ok := t.state.LastResult(node.Agent); ok {
+			degradation.Outcome = domain.OutcomeCached
+			resp = AgentResponse{Result: cached, Cached: true, Degraded: &degradation}
+		}
+	}
+
+	_ = t.streamFn(Event{
+		Type:    EventDegraded,
+		Agent:   node.Agent,
+		Phase:   PhaseFailed,
+		Code:    errorCode(err),
+		Text:    degradation.Caveat(),
+		Payload: degradation,
+	})
+	if resp.Cached {
+		// Replaces whatever the failed attempt streamed before giving up.
+		_ = t.streamFn(reusedEvent(node.Agent, resp.artifact(node.Agent)))
+	}
+	return resp, nil
+}
+
+func runStep(ctx context.Context, m *MultiAgentOrchestrator, t *turn, node Node, deps []NodeResult) (AgentResponse, error) {
+	if node.Policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, node.Policy.Timeout)
+		defer cancel()
+	}
+
+	resp, err := node.Step(m, ctx, t, deps)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		err = fmt.Errorf("%w (%s timeout %s)", err, node.Agent, node.Policy.Timeout)
+	}
+	return resp, err
+}
+
+// findCycle returns the agents of a dependency cycle, or nil when there is none.
+func findCycle(nodes []Node, byAgent map[domain.Agent]Node) []domain.Agent {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[domain.Agent]int, len(nodes))
+	var path []domain.Agent
+
+	var visit func(agent domain.Agent) []domain.Agent
+	visit = func(agent domain.Agent) []domain.Agent {
+		switch state[agent] {
+		case visiting:
+			for i, a := range path {
+				if a == agent {
+					return append(append([]domain.Agent(nil), path[i:]...), agent)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		state[agent] = visiting
+		path = append(path, agent)
+		for _, dep := range byAgent[agent].DependsOn {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[agent] = visited
+		return nil
+	}
+
+	for _, node := range nodes {
+		if cycle := visit(node.Agent); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func joinAgents(agents []domain.Agent, sep string) string {
+	names := make([]string, len(agents))
+	for i, agent := range agents {
+		names[i] = string(agent)
+	}
+	return strings.Join(names, sep)
+}
@@ -0,0 +1,320 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	nodeA domain.Agent = "a"
+	nodeB domain.Agent = "b"
+	nodeC domain.Agent = "c"
+	nodeD domain.Agent = "d"
+)
+
+// recordingTurn is a turn whose events are kept in order, as "type: text", or
+// "type: payload" with the payload in JSON for events that carry one.
+type recordingTurn struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingTurn) turn(state *domain.TripState) *turn {
+	if state == nil {
+		state = domain.NewTripState(uuid.New(), uuid.New())
+	}
+	return &turn{state: state, streamFn: func(e Event) error {
+		data := e.Text
+		if e.Payload != nil {
+			raw, _ := json.Marshal(e.Payload)
+			data = string(raw)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, string(e.Type)+": "+data)
+		return nil
+	}}
+}
+
+func answer(result string) StepFunc {
+	return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		for _, dep := range deps {
+			result += "<" + dep.Response.Result
+		}
+		return AgentResponse{Result: result}, nil
+	}
+}
+
+func TestPipelineBuilder_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *PipelineBuilder
+		want    string
+	}{
+		{"empty", NewPipelineBuilder(), "no nodes"},
+		{"duplicate", NewPipelineBuilder().Node(nodeA, answer("a")).Node(nodeA, answer("a")), "duplicate node a"},
+		{"unknown dependency", NewPipelineBuilder().Node(nodeA, answer("a"), nodeB), "a depends on unknown node b"},
+		{"missing step", NewPipelineBuilder().Node(nodeA, nil), "a has no step"},
+		{
+			"cycle",
+			NewPipelineBuilder().Node(nodeA, answer("a")).Node(nodeB, answer("b"), nodeA, nodeC).Node(nodeC, answer("c"), nodeB),
+			"dependency cycle b -> c -> b",
+		},
+		{"two final nodes", NewPipelineBuilder().Node(nodeA, answer("a")).Node(nodeB, answer("b")), "want exactly one final node, got a, b"},
+		{
+			"required node that degrades",
+			NewPipelineBuilder().Node(nodeA, answer("a")).Policy(nodeA, domain.AgentPolicy{OnFailure: domain.PolicyCached}).Require(nodeA),
+			"a is required, so its failure policy must be fail or retry, got cached",
+		},
+		{"unknown required node", NewPipelineBuilder().Node(nodeA, answer("a")).Require(nodeB), "b has no step"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestPipeline_ExecuteRunsIndependentNodesInParallel(t *testing.T) {
+	// b and c only finish once both started, so the run deadlocks unless they
+	// run concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	meet := func(result string) StepFunc {
+		return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			started.Done()
+			started.Wait()
+			return answer(result)(m, ctx, t, deps)
+		}
+	}
+
+	pipeline, err := NewPipelineBuilder().
+		Node(nodeD, answer("d"), nodeC, nodeB).
+		Node(nodeB, meet("b"), nodeA).
+		Node(nodeC, meet("c"), nodeA).
+		Node(nodeA, answer("a")).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, nodeD, pipeline.Sink())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := pipeline.Execute(ctx, nil, (&recordingTurn{}).turn(nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, "d<c<a<b<a", results[nodeD].Result, "dependencies are passed in declaration order")
+}
+
+func TestPipeline_ExecuteStopsOnStepError(t *testing.T) {
+	var ranAfterFailure bool
+	pipeline := NewPipelineBuilder().
+		Node(nodeA, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			return AgentResponse{}, errors.New("extraction failed")
+		}).
+		Node(nodeB, answer("b")).
+		Node(nodeC, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			ranAfterFailure = true
+			return AgentResponse{}, nil
+		}, nodeA, nodeB).
+		MustBuild()
+
+	events := &recordingTurn{}
+	results, err := pipeline.Execute(context.Background(), nil, events.turn(nil))
+	assert.EqualError(t, err, "extraction failed")
+	assert.False(t, ranAfterFailure)
+	assert.NotContains(t, results, nodeA)
+	assert.Contains(t, events.events, "error: extraction failed")
+}
+
+func TestPipeline_ExecutePausesOnClarification(t *testing.T) {
+	var ranAfterPause bool
+	pipeline := NewPipelineBuilder().
+		Node(nodeA, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			clarification := domain.NewClarification([]domain.IntentField{domain.FieldDestinations}, "Where to?", domain.LocaleEnglish)
+			return AgentResponse{Clarification: &clarification}, nil
+		}).
+		Node(nodeB, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			ranAfterPause = true
+			return AgentResponse{}, nil
+		}, nodeA).
+		MustBuild()
+
+	results, err := pipeline.Execute(context.Background(), nil, (&recordingTurn{}).turn(nil))
+	require.NoError(t, err)
+	assert.False(t, ranAfterPause)
+	require.Contains(t, results, nodeA)
+	assert.Equal(t, "Where to?", results[nodeA].Clarification.Question)
+	assert.NotContains(t, results, nodeB)
+}
+
+func TestPipeline_FailurePolicies(t *testing.T) {
+	hang := func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		<-ctx.Done()
+		return AgentResponse{}, ctx.Err()
+	}
+	failOnce := func() StepFunc {
+		var calls int
+		return func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			calls++
+			if calls == 1 {
+				return AgentResponse{}, errors.New("upstream 503")
+			}
+			return AgentResponse{Result: "fresh"}, nil
+		}
+	}
+	failing := func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+		return AgentResponse{}, errors.New("upstream 503")
+	}
+
+	tests := []struct {
+		name       string
+		step       StepFunc
+		policy     domain.AgentPolicy
+		cached     string
+		wantResult string
+		wantEvent  string
+	}{
+		{
+			name:      "timeout then continue without",
+			step:      hang,
+			policy:    domain.AgentPolicy{Timeout: 20 * time.Millisecond, OnFailure: domain.PolicyContinue},
+			wantEvent: `degraded: {"agent":"b","policy":"continue","outcome":"omitted","reason":"context deadline exceeded (b timeout 20ms)"}`,
+		},
+		{
+			name:       "retry once",
+			step:       failOnce(),
+			policy:     domain.AgentPolicy{OnFailure: domain.PolicyRetry},
+			wantResult: "fresh",
+			wantEvent:  "status: Retrying b after error: upstream 503",
+		},
+		{
+			name:       "substitute cached answer",
+			step:       failing,
+			policy:     domain.AgentPolicy{OnFailure: domain.PolicyCached},
+			cached:     "from last turn",
+			wantResult: "from last turn",
+			wantEvent:  `degraded: {"agent":"b","policy":"cached","outcome":"cached","reason":"upstream 503"}`,
+		},
+		{
+			name:      "cached policy without a cached answer",
+			step:      failing,
+			policy:    domain.AgentPolicy{OnFailure: domain.PolicyCached},
+			wantEvent: `degraded: {"agent":"b","policy":"cached","outcome":"omitted","reason":"upstream 503"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := NewPipelineBuilder().
+				Node(nodeA, answer("a")).
+				Node(nodeB, tt.step).
+				Node(nodeC, answer("c"), nodeA, nodeB).
+				Policy(nodeB, tt.policy).
+				MustBuild()
+
+			state := domain.NewTripState(uuid.New(), uuid.New())
+			if tt.cached != "" {
+				state.RecordResult(nodeB, "older inputs", tt.cached)
+			}
+			events := &recordingTurn{}
+			results, err := pipeline.Execute(context.Background(), nil, events.turn(state))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantResult, results[nodeB].Result)
+			assert.Contains(t, events.events, tt.wantEvent)
+			assert.Equal(t, "c<a<"+tt.wantResult, results[nodeC].Result, "the run goes on")
+		})
+	}
+}
+
+func TestPipeline_RequiredNodeFailsAfterItsRetry(t *testing.T) {
+	var calls int
+	pipeline := NewPipelineBuilder().
+		Node(nodeA, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			calls++
+			return AgentResponse{}, errors.New("upstream 503")
+		}).
+		Node(nodeB, answer("b"), nodeA).
+		Policy(nodeA, domain.AgentPolicy{OnFailure: domain.PolicyRetry}).
+		Require(nodeA).
+		MustBuild()
+
+	events := &recordingTurn{}
+	results, err := pipeline.Execute(context.Background(), nil, events.turn(nil))
+	require.EqualError(t, err, "upstream 503")
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []string{"status: Retrying a after error: upstream 503", "error: upstream 503"}, events.events)
+	assert.NotContains(t, results, nodeB, "nothing runs on a missing required answer")
+}
+
+func TestPipeline_CachedFallbackIsStreamed(t *testing.T) {
+	pipeline := NewPipelineBuilder().
+		Node(domain.BudgetPlanner, func(m *MultiAgentOrchestrator, ctx context.Context, t *turn, deps []NodeResult) (AgentResponse, error) {
+			_ = t.streamFn(Event{Type: EventBudgetDelta, Agent: domain.BudgetPlanner, Text: "1. **Pe"})
+			return AgentResponse{}, errors.New("stream interrupted")
+		}).
+		Policy(domain.BudgetPlanner, domain.AgentPolicy{OnFailure: domain.PolicyCached}).
+		MustBuild()
+
+	state := domain.NewTripState(uuid.New(), uuid.New())
+	state.RecordResult(domain.BudgetPlanner, "older inputs", "1. **Peru** ~$1,800 USD")
+	events := &recordingTurn{}
+	_, err := pipeline.Execute(context.Background(), nil, events.turn(state))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"budget.delta: 1. **Pe",
+		`degraded: {"agent":"budget_planner","policy":"cached","outcome":"cached","reason":"stream interrupted"}`,
+		"budget.delta: 1. **Peru** ~$1,800 USD",
+	}, events.events)
+}
+
+func TestPipeline_WithPolicies(t *testing.T) {
+	pipeline := TravelPipeline()
+
+	_, err := pipeline.WithPolicies(map[domain.Agent]domain.AgentPolicy{"weather_agent": {OnFailure: domain.PolicyFail}})
+	assert.EqualError(t, err, "pipeline: policy for unknown node weather_agent")
+
+	_, err = pipeline.WithPolicies(map[domain.Agent]domain.AgentPolicy{domain.BudgetPlanner: {OnFailure: "ignore"}})
+	assert.EqualError(t, err, `pipeline: budget_planner: unknown failure policy "ignore"`)
+
+	_, err = pipeline.WithPolicies(map[domain.Agent]domain.AgentPolicy{domain.InformationExtractor: {OnFailure: domain.PolicyContinue}})
+	assert.EqualError(t, err, "pipeline: information_extractor is required, so its failure policy must be fail or retry, got continue")
+
+	configured, err := pipeline.WithPolicies(map[domain.Agent]domain.AgentPolicy{
+		domain.BudgetPlanner: {Timeout: time.Minute, OnFailure: domain.PolicyCached},
+	})
+	require.NoError(t, err)
+	for _, node := range configured.Nodes() {
+		if node.Agent == domain.BudgetPlanner {
+			assert.Equal(t, domain.AgentPolicy{Timeout: time.Minute, OnFailure: domain.PolicyCached}, node.Policy)
+		}
+	}
+}
+
+func TestPipeline_WithStep(t *testing.T) {
+	pipeline := TravelPipeline()
+
+	_, err := pipeline.WithStep("weather_agent", RunAgent("weather_agent"))
+	assert.EqualError(t, err, "pipeline: step for unknown node weather_agent")
+
+	replaced, err := pipeline.WithStep(domain.BudgetPlanner, RunAgent(domain.BudgetPlanner))
+	require.NoError(t, err)
+	assert.Equal(t, len(pipeline.Nodes()), len(replaced.Nodes()))
+	for i, node := range replaced.Nodes() {
+		assert.Equal(t, pipeline.Nodes()[i].Policy, node.Policy, "policies are kept")
+	}
+}
@@ -0,0 +1,42 @@
+package application
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by ChatRepository implementations.
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrConversationNotOwned = errors.New("conversation belongs to another user")
+	ErrConversationExists   = errors.New("conversation already exists")
+	ErrTripStateNotFound    = errors.New("trip state not found")
+)
+
+// ChatRepository persists the user-facing transcript of a conversation.
+// The conversation ID is the ID of the stored domain.Chat.
+type ChatRepository interface {
+	Save(ctx context.Context, chat *domain.Chat) error
+	// Create stores a new conversation, or returns ErrConversationExists when
+	// one with the same ID was stored first.
+	Create(ctx context.Context, chat *domain.Chat) error
+	Load(ctx context.Context, conversationID, userID uuid.UUID) (*domain.Chat, error)
+	AppendMessages(ctx context.Context, conversationID, userID uuid.UUID, messages ...domain.Message) error
+}
+
+// TripStateRepository persists what the orchestrator learned about a trip between
+// turns: the merged intent and the last answer of each agent.
+type TripStateRepository interface {
+	LoadTripState(ctx context.Context, conversationID, userID uuid.UUID) (*domain.TripState, error)
+	SaveTripState(ctx context.Context, state *domain.TripState) error
+}
+
+// AgentSessionRepository persists the sub-session of every agent call so the
+// reasoning behind a recommendation can be inspected after the fact.
+type AgentSessionRepository interface {
+	SaveAgentSession(ctx context.Context, userID uuid.UUID, session *domain.AgentSession) error
+	ListAgentSessions(ctx context.Context, conversationID, userID uuid.UUID) ([]*domain.AgentSession, error)
+}
@@ -0,0 +1,50 @@
+package application
+
+import (
+	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// StructuredRunner runs any registered structured agent: the system prompt is
+// rendered from the injection, followed by the given chat, and the model's
+// answer is decoded into a new value of the type of the definition's Result.
+// The returned session chat holds the messages the model saw.
+type StructuredRunner struct {
+	client domain.LLMClient
+}
+
+func NewStructuredRunner(client domain.LLMClient) *StructuredRunner {
+	return &StructuredRunner{client: client}
+}
+
+func (u *StructuredRunner) Run(
+	ctx context.Context,
+	chat *domain.Chat,
+	injection domain.Injection,
+	model domain.LLMModel,
+) (*domain.Chat, any, error) {
+	def := injection.Agent
+	if def.Result == nil {
+		return nil, nil, fmt.Errorf("%s has no structured result type", def.Name)
+	}
+
+	sessionChat, err := domain.NewAgentSessionFromInjection(def.Name, chat.UserID, injection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := sessionChat.AppendMessagesFrom(chat); err != nil {
+		return nil, nil, err
+	}
+
+	session := llm.NewLLMModelSession(u.client, string(model))
+
+	out := reflect.New(reflect.TypeOf(def.Result))
+	if err := llm.StructuredOutputInto(ctx, session, sessionChat.Messages, out.Interface()); err != nil {
+		return sessionChat, nil, err
+	}
+	return sessionChat, out.Elem().Interface(), nil
+}
@@ -0,0 +1,70 @@
+package application
+
+import (
+	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// exchangeRateArgs are the arguments of the exchange_rate tool.
+type exchangeRateArgs struct {
+	From string `json:"from" jsonschema:"description=ISO 4217 code of the currency to convert from, e.g. USD"`
+	To   string `json:"to" jsonschema:"description=ISO 4217 code of the currency to convert to, e.g. PEN"`
+}
+
+// NewTravelTools registers the Go tools the agents may call: today's date and,
+// when rates is set, the exchange rate between two currencies. They are the
+// only facts the agents look up; prices and opening seasons still come from the
+// model. now is injected so tests can pin the date.
+func NewTravelTools(now func() time.Time, rates domain.ExchangeRateProvider) (*domain.ToolRegistry, error) {
+	tools := []domain.Tool{{
+		Name:        "current_date",
+		Description: "Returns today's date (YYYY-MM-DD) and weekday. Use it to reason about seasons, weather and how far away a trip is.",
+		Status:      "Checking today's date",
+		Handler: func(ctx context.Context, _ json.RawMessage) (string, error) {
+			today := now()
+			return today.Format("2006-01-02") + " (" + today.Weekday().String() + ")", nil
+		},
+	}}
+	if rates != nil {
+		tools = append(tools, domain.Tool{
+			Name:        "exchange_rate",
+			Description: "Returns how many units of one currency one unit of another buys, and the date of the rate. Use it to tell how far the traveler's money goes at a destination.",
+			Parameters:  llm.GenerateSchema[exchangeRateArgs](),
+			Status:      "Checking exchange rates",
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				return lookUpExchangeRate(ctx, rates, arguments)
+			},
+		})
+	}
+
+	// The budget planner answers in structured output mode, which takes no tools.
+	registry := domain.NewToolRegistry()
+	if err := registry.Register(domain.DestinationExpert, tools...); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func lookUpExchangeRate(ctx context.Context, rates domain.ExchangeRateProvider, arguments json.RawMessage) (string, error) {
+	var args exchangeRateArgs
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	from, ok := domain.ParseCurrency(args.From)
+	if !ok {
+		return "", fmt.Errorf("invalid currency %q", args.From)
+	}
+	to, ok := domain.ParseCurrency(args.To)
+	if !ok {
+		return "", fmt.Errorf("invalid currency %q", args.To)
+	}
+	rate, err := rates.Rate(ctx, from, to)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (as of %s)", rate, rate.AsOf.Format(time.DateOnly)), nil
+}
@@ -0,0 +1,48 @@
+package application_test
+
+import (
+	"acai_travel/internal/chat/adapters/exchange"
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTravelTools(t *testing.T) {
+	ctx := context.Background()
+	now := func() time.Time { return time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC) }
+	rates, err := exchange.NewStaticTable(domain.CurrencyUSD, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), map[domain.Currency]float64{"PEN": 3.75})
+	require.NoError(t, err)
+
+	tools, err := application.NewTravelTools(now, rates)
+	require.NoError(t, err)
+	toolbox := domain.Toolbox{Tools: tools.For(domain.DestinationExpert)}
+	assert.Empty(t, tools.For(domain.BudgetPlanner), "structured output takes no tools")
+
+	date, ok := toolbox.Find("current_date")
+	require.True(t, ok)
+	result, err := date.Handler(ctx, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-18 (Sunday)", result)
+
+	rate, ok := toolbox.Find("exchange_rate")
+	require.True(t, ok)
+	assert.NotNil(t, rate.Parameters)
+	result, err = rate.Handler(ctx, json.RawMessage(`{"from":"usd","to":"PEN"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "1 USD = 3.75 PEN (as of 2026-10-01)", result)
+	_, err = rate.Handler(ctx, json.RawMessage(`{"from":"USD","to":"XYZ"}`))
+	assert.ErrorIs(t, err, domain.ErrUnknownCurrency)
+	_, err = rate.Handler(ctx, json.RawMessage(`{"from":"USD","to":"soles"}`))
+	assert.ErrorContains(t, err, `invalid currency "soles"`)
+
+	tools, err = application.NewTravelTools(now, nil)
+	require.NoError(t, err)
+	_, ok = (domain.Toolbox{Tools: tools.For(domain.DestinationExpert)}).Find("exchange_rate")
+	assert.False(t, ok, "without exchange rates there is nothing to look up")
+}
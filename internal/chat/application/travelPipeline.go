@@ -0,0 +1,42 @@
+package application
+
+import "acai_travel/internal/chat/domain"
+
+// TravelPipeline is the default agent pipeline: the input guard screens the
+// user's message, the extractor updates the trip details, the destination
+// expert and budget planner then run in parallel, the synthesizer turns their
+// answers into the reply, and the grounding verifier has it regenerated once if
+// it made up destinations or prices. To add or reorder agents, declare another
+// pipeline and pass it to NewMultiAgentOrchestrator; agents registered with
+// TravelAgents run with RunAgent.
+//
+// Without configured policies a missing recommendation or budget does not stop
+// the run, while the extractor, the synthesizer and the grounding verifier are
+// required: their policy can only be fail or retry. When the
+// guard's classifier fails, the known-attack check still applies. Budgets are
+// categorized with domain.DefaultBudgetRules; replace the budget planner's step
+// with WithStep to use other thresholds.
+func TravelPipeline() *Pipeline {
+	return NewPipelineBuilder().
+		Node(domain.InputGuard, GuardInput).
+		Node(domain.InformationExtractor, ExtractIntent, domain.InputGuard).
+		Node(domain.DestinationExpert, RunAgent(domain.DestinationExpert), domain.InformationExtractor).
+		Node(domain.BudgetPlanner, PlanBudget(domain.DefaultBudgetRules), domain.InformationExtractor).
+		Node(domain.TripSynthesizer, Synthesize, domain.BudgetPlanner, domain.DestinationExpert).
+		Node(domain.GroundingVerifier, VerifyGrounding(domain.GroundingRegenerate), domain.TripSynthesizer, domain.BudgetPlanner, domain.DestinationExpert).
+		Policy(domain.InputGuard, domain.AgentPolicy{OnFailure: domain.PolicyContinue}).
+		Policy(domain.DestinationExpert, domain.AgentPolicy{OnFailure: domain.PolicyContinue}).
+		Policy(domain.BudgetPlanner, domain.AgentPolicy{OnFailure: domain.PolicyContinue}).
+		Require(domain.InformationExtractor, domain.TripSynthesizer, domain.GroundingVerifier).
+		MustBuild()
+}
+
+// The steps of the agents that do more than answer from their template: the
+// guard may block the message, the extractor merges the trip details and may
+// pause the run, and the synthesizer turns the answers of its dependencies into
+// the reply.
+var (
+	GuardInput    StepFunc = (*MultiAgentOrchestrator).guardInput
+	ExtractIntent StepFunc = (*MultiAgentOrchestrator).extractIntent
+	Synthesize    StepFunc = (*MultiAgentOrchestrator).synthesize
+)
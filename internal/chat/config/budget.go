@@ -0,0 +1,37 @@
+package config
+
+import (
+	"acai_travel/internal/chat/domain"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//go:embed budget_rules.json
+var defaultBudgetRules []byte
+
+// LoadBudgetRules reads the thresholds of the budget categories, in USD per
+// traveler and night, from path, or from the embedded budget_rules.json when
+// path is empty.
+func LoadBudgetRules(path string) (domain.BudgetRules, error) {
+	data := defaultBudgetRules
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return domain.BudgetRules{}, fmt.Errorf("budget rules: %w", err)
+		}
+	}
+
+	var rules domain.BudgetRules
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return domain.BudgetRules{}, fmt.Errorf("budget rules: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return domain.BudgetRules{}, err
+	}
+	return rules, nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"acai_travel/internal/chat/domain"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBudgetRules(t *testing.T) {
+	rules, err := LoadBudgetRules("")
+	require.NoError(t, err)
+	assert.Equal(t, domain.DefaultBudgetRules, rules)
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	rules, err = LoadBudgetRules(write("custom.json", `{"lowBelow": 80, "highFrom": 200}`))
+	require.NoError(t, err)
+	assert.Equal(t, domain.BudgetRules{LowBelow: 80, HighFrom: 200}, rules)
+
+	_, err = LoadBudgetRules(write("reversed.json", `{"lowBelow": 300, "highFrom": 200}`))
+	assert.Error(t, err)
+	_, err = LoadBudgetRules(write("typo.json", `{"lowBellow": 80, "highFrom": 200}`))
+	assert.Error(t, err)
+	_, err = LoadBudgetRules(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"acai_travel/internal/chat/domain"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+//go:embed models.json
+var defaultModelsConfig []byte
+
+// ModelConfig is the model registry plus the model, deadline and failure policy
+// assigned to each agent. Agents missing from the file keep their default model.
+type ModelConfig struct {
+	Registry      *domain.ModelRegistry
+	AgentModels   map[domain.Agent]domain.LLMModel
+	AgentPolicies map[domain.Agent]domain.AgentPolicy
+}
+
+type modelsFile struct {
+	Models []struct {
+		Alias               string   `json:"alias"`
+		ProviderModel       string   `json:"providerModel"`
+		ContextWindow       int      `json:"contextWindow"`
+		InputPricePerToken  float64  `json:"inputPricePerToken"`
+		OutputPricePerToken float64  `json:"outputPricePerToken"`
+		Capabilities        []string `json:"capabilities"`
+	} `json:"models"`
+	Agents map[string]struct {
+		Model     string `json:"model"`
+		Timeout   string `json:"timeout"`   // e.g. "45s"; empty means no per-agent deadline
+		OnFailure string `json:"onFailure"` // fail, continue, retry or cached
+	} `json:"agents"`
+}
+
+// LoadModelConfig reads the model registry from path, or from the embedded
+// models.json when path is empty, and validates that the model of every agent
+// in agents supports what the agent needs.
+func LoadModelConfig(path string, agents *domain.AgentRegistry) (*ModelConfig, error) {
+	data := defaultModelsConfig
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("model config: %w", err)
+		}
+	}
+	return parseModelConfig(data, agents)
+}
+
+func parseModelConfig(data []byte, agents *domain.AgentRegistry) (*ModelConfig, error) {
+	var file modelsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("model config: %w", err)
+	}
+
+	specs := make([]domain.ModelSpec, 0, len(file.Models))
+	for _, m := range file.Models {
+		caps := make([]domain.Capability, 0, len(m.Capabilities))
+		for _, c := range m.Capabilities {
+			caps = append(caps, domain.Capability(c))
+		}
+		specs = append(specs, domain.ModelSpec{
+			Alias:               domain.LLMModel(m.Alias),
+			ProviderModel:       m.ProviderModel,
+			ContextWindow:       m.ContextWindow,
+			InputPricePerToken:  m.InputPricePerToken,
+			OutputPricePerToken: m.OutputPricePerToken,
+			Capabilities:        caps,
+		})
+	}
+
+	registry, err := domain.NewModelRegistry(specs...)
+	if err != nil {
+		return nil, fmt.Errorf("model config: %w", err)
+	}
+
+	agentModels := agents.DefaultModels()
+	agentPolicies := make(map[domain.Agent]domain.AgentPolicy)
+	for agent, a := range file.Agents {
+		if _, known := agents.Definition(domain.Agent(agent)); !known {
+			return nil, fmt.Errorf("model config: unknown agent %q", agent)
+		}
+		if a.Model != "" {
+			agentModels[domain.Agent(agent)] = domain.LLMModel(a.Model)
+		}
+
+		if a.Timeout == "" && a.OnFailure == "" {
+			continue
+		}
+		policy := domain.AgentPolicy{OnFailure: domain.FailurePolicy(a.OnFailure)}
+		if a.Timeout != "" {
+			timeout, err := time.ParseDuration(a.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("model config: %s timeout: %w", agent, err)
+			}
+			policy.Timeout = timeout
+		}
+		if policy.Timeout < 0 {
+			return nil, fmt.Errorf("model config: %s: negative timeout %s", agent, policy.Timeout)
+		}
+		if policy.OnFailure != "" && !policy.OnFailure.Valid() {
+			return nil, fmt.Errorf("model config: %s: unknown failure policy %q", agent, policy.OnFailure)
+		}
+		agentPolicies[domain.Agent(agent)] = policy
+	}
+
+	if err := registry.ValidateAssignments(agentModels, agents.Requirements()); err != nil {
+		return nil, fmt.Errorf("model config: %w", err)
+	}
+
+	return &ModelConfig{Registry: registry, AgentModels: agentModels, AgentPolicies: agentPolicies}, nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadModelConfig_Embedded(t *testing.T) {
+	cfg, err := LoadModelConfig("", application.TravelAgents())
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.LLMModel("gpt-4o"), cfg.AgentModels[domain.InformationExtractor])
+	assert.Equal(t, domain.AgentPolicy{Timeout: time.Minute, OnFailure: domain.PolicyCached}, cfg.AgentPolicies[domain.BudgetPlanner])
+	spec, err := cfg.Registry.Resolve("gpt-3.5")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-3.5-turbo", spec.ProviderModel)
+}
+
+func TestLoadModelConfig_File(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "models.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("local model for every agent", func(t *testing.T) {
+		cfg, err := LoadModelConfig(write(t, `{
+			"models": [{"alias": "local", "providerModel": "llama-3.1-8b-instruct", "contextWindow": 8192,
+				"capabilities": ["chat", "streaming", "structured_output"]}],
+			"agents": {
+				"input_guard": {"model": "local"},
+				"information_extractor": {"model": "local"},
+				"destination_expert": {"model": "local"},
+				"budget_planner": {"model": "local"},
+				"trip_synthesizer": {"model": "local"}
+			}
+		}`), application.TravelAgents())
+		require.NoError(t, err)
+		assert.Equal(t, domain.LLMModel("local"), cfg.AgentModels[domain.TripSynthesizer])
+	})
+
+	t.Run("model lacking a capability is rejected", func(t *testing.T) {
+		_, err := LoadModelConfig(write(t, `{
+			"models": [{"alias": "chat-only", "providerModel": "m", "capabilities": ["chat"]}],
+			"agents": {
+				"input_guard": {"model": "chat-only"},
+				"information_extractor": {"model": "chat-only"},
+				"destination_expert": {"model": "chat-only"},
+				"budget_planner": {"model": "chat-only"},
+				"trip_synthesizer": {"model": "chat-only"}
+			}
+		}`), application.TravelAgents())
+		assert.ErrorContains(t, err, "information_extractor: model chat-only does not support structured_output")
+		assert.ErrorContains(t, err, "budget_planner: model chat-only does not support structured_output")
+		assert.ErrorContains(t, err, "trip_synthesizer: model chat-only does not support streaming")
+	})
+
+	t.Run("agent policies", func(t *testing.T) {
+		cfg, err := LoadModelConfig(write(t, `{
+			"models": [{"alias": "local", "providerModel": "m", "capabilities": ["chat", "streaming", "structured_output"]}],
+			"agents": {
+				"input_guard": {"model": "local"},
+				"information_extractor": {"model": "local"},
+				"destination_expert": {"model": "local", "timeout": "45s"},
+				"budget_planner": {"model": "local", "onFailure": "continue"},
+				"trip_synthesizer": {"model": "local"}
+			}
+		}`), application.TravelAgents())
+		require.NoError(t, err)
+		assert.Equal(t, map[domain.Agent]domain.AgentPolicy{
+			domain.DestinationExpert: {Timeout: 45 * time.Second},
+			domain.BudgetPlanner:     {OnFailure: domain.PolicyContinue},
+		}, cfg.AgentPolicies)
+
+		_, err = LoadModelConfig(write(t, `{
+			"models": [{"alias": "local", "providerModel": "m", "capabilities": ["chat", "streaming", "structured_output"]}],
+			"agents": {"budget_planner": {"model": "local", "onFailure": "ignore"}}
+		}`), application.TravelAgents())
+		assert.ErrorContains(t, err, `budget_planner: unknown failure policy "ignore"`)
+
+		_, err = LoadModelConfig(write(t, `{
+			"models": [{"alias": "local", "providerModel": "m", "capabilities": ["chat", "streaming", "structured_output"]}],
+			"agents": {"budget_planner": {"model": "local", "timeout": "soon"}}
+		}`), application.TravelAgents())
+		assert.ErrorContains(t, err, "budget_planner timeout")
+	})
+
+	t.Run("agents without an entry keep their default model", func(t *testing.T) {
+		agents := application.TravelAgents()
+		require.NoError(t, agents.Register(domain.AgentDefinition{
+			Name:         "visa_advisor",
+			Template:     "Explain the visa rules for {{.destination}}.",
+			RequiredKeys: []string{"destination"},
+			DefaultModel: "local",
+			Output:       domain.OutputText,
+		}))
+		cfg, err := LoadModelConfig(write(t, `{
+			"models": [
+				{"alias": "local", "providerModel": "m", "capabilities": ["chat", "streaming", "structured_output"]},
+				{"alias": "gpt-4o", "providerModel": "gpt-4o", "capabilities": ["chat", "streaming", "structured_output"]},
+				{"alias": "gpt-4", "providerModel": "gpt-4", "capabilities": ["chat", "streaming"]}
+			],
+			"agents": {"budget_planner": {"model": "local"}, "visa_advisor": {"timeout": "10s"}}
+		}`), agents)
+		require.NoError(t, err)
+		assert.Equal(t, domain.LLMModel("local"), cfg.AgentModels[domain.BudgetPlanner])
+		assert.Equal(t, domain.LLMModel("gpt-4"), cfg.AgentModels[domain.DestinationExpert])
+		assert.Equal(t, domain.LLMModel("local"), cfg.AgentModels["visa_advisor"])
+		assert.Equal(t, domain.AgentPolicy{Timeout: 10 * time.Second}, cfg.AgentPolicies["visa_advisor"])
+	})
+
+	t.Run("unknown agent is rejected", func(t *testing.T) {
+		_, err := LoadModelConfig(write(t, `{"models": [], "agents": {"budget_planer": {"model": "x"}}}`), application.TravelAgents())
+		assert.ErrorContains(t, err, `unknown agent "budget_planer"`)
+	})
+}
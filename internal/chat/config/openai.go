@@ -0,0 +1,107 @@
+package config
+
+import (
+	"acai_travel/internal/chat/adapters/llm"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// OpenAIConfig describes how to reach an OpenAI-compatible endpoint.
+type OpenAIConfig struct {
+	APIKey       string
+	BaseURL      string
+	Organization string
+	Project      string
+	Headers      map[string]string
+	QueryParams  map[string]string
+	Timeout      time.Duration
+	ProxyURL     *url.URL
+}
+
+// LoadOpenAIConfig reads the OpenAI client settings from the environment:
+//
+//	OPENAI_API_KEY        API key (sent as a bearer token)
+//	OPENAI_BASE_URL       endpoint, e.g. http://localhost:8000/v1 for vLLM
+//	OPENAI_ORG_ID         OpenAI-Organization header
+//	OPENAI_PROJECT_ID     OpenAI-Project header
+//	OPENAI_EXTRA_HEADERS  extra headers as "Name=value;Other=value"
+//	OPENAI_QUERY_PARAMS   extra query parameters as "api-version=2024-10-21"
+//	OPENAI_TIMEOUT        per-request timeout, e.g. 90s
+//	OPENAI_PROXY_URL      HTTP(S) proxy for outgoing requests
+func LoadOpenAIConfig() (OpenAIConfig, error) {
+	cfg := OpenAIConfig{
+		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		BaseURL:      os.Getenv("OPENAI_BASE_URL"),
+		Organization: os.Getenv("OPENAI_ORG_ID"),
+		Project:      os.Getenv("OPENAI_PROJECT_ID"),
+	}
+
+	var err error
+	if cfg.Headers, err = parsePairs(os.Getenv("OPENAI_EXTRA_HEADERS")); err != nil {
+		return cfg, fmt.Errorf("OPENAI_EXTRA_HEADERS: %w", err)
+	}
+	if cfg.QueryParams, err = parsePairs(os.Getenv("OPENAI_QUERY_PARAMS")); err != nil {
+		return cfg, fmt.Errorf("OPENAI_QUERY_PARAMS: %w", err)
+	}
+
+	if raw := os.Getenv("OPENAI_TIMEOUT"); raw != "" {
+		if cfg.Timeout, err = time.ParseDuration(raw); err != nil {
+			return cfg, fmt.Errorf("OPENAI_TIMEOUT: %w", err)
+		}
+	}
+
+	if raw := os.Getenv("OPENAI_PROXY_URL"); raw != "" {
+		if cfg.ProxyURL, err = url.Parse(raw); err != nil {
+			return cfg, fmt.Errorf("OPENAI_PROXY_URL: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// ClientOptions converts the configuration into llm client options.
+func (c OpenAIConfig) ClientOptions() []llm.ClientOption {
+	var opts []llm.ClientOption
+	if c.BaseURL != "" {
+		opts = append(opts, llm.WithBaseURL(c.BaseURL))
+	}
+	if c.Organization != "" {
+		opts = append(opts, llm.WithOrganization(c.Organization))
+	}
+	if c.Project != "" {
+		opts = append(opts, llm.WithProject(c.Project))
+	}
+	for k, v := range c.Headers {
+		opts = append(opts, llm.WithHeader(k, v))
+	}
+	for k, v := range c.QueryParams {
+		opts = append(opts, llm.WithQueryParam(k, v))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, llm.WithRequestTimeout(c.Timeout))
+	}
+	if c.ProxyURL != nil {
+		opts = append(opts, llm.WithProxy(c.ProxyURL))
+	}
+	return opts
+}
+
+// parsePairs parses "key=value;key=value" lists.
+func parsePairs(raw string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, item := range strings.Split(raw, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", item)
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
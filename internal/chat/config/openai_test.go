@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOpenAIConfig(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", "https://acai.openai.azure.com/openai/v1")
+	t.Setenv("OPENAI_EXTRA_HEADERS", "api-key=azure-key; X-Team=travel")
+	t.Setenv("OPENAI_QUERY_PARAMS", "api-version=2024-10-21")
+	t.Setenv("OPENAI_TIMEOUT", "90s")
+	t.Setenv("OPENAI_PROXY_URL", "http://proxy.corp:3128")
+
+	cfg, err := LoadOpenAIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://acai.openai.azure.com/openai/v1", cfg.BaseURL)
+	assert.Equal(t, map[string]string{"api-key": "azure-key", "X-Team": "travel"}, cfg.Headers)
+	assert.Equal(t, map[string]string{"api-version": "2024-10-21"}, cfg.QueryParams)
+	assert.Equal(t, 90*time.Second, cfg.Timeout)
+	assert.Equal(t, "proxy.corp:3128", cfg.ProxyURL.Host)
+	assert.Len(t, cfg.ClientOptions(), 6)
+}
+
+func TestLoadOpenAIConfig_Invalid(t *testing.T) {
+	t.Setenv("OPENAI_EXTRA_HEADERS", "missing-equals")
+	_, err := LoadOpenAIConfig()
+	assert.ErrorContains(t, err, "OPENAI_EXTRA_HEADERS")
+
+	t.Setenv("OPENAI_EXTRA_HEADERS", "")
+	t.Setenv("OPENAI_TIMEOUT", "soon")
+	_, err = LoadOpenAIConfig()
+	assert.ErrorContains(t, err, "OPENAI_TIMEOUT")
+}
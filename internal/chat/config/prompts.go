@@ -0,0 +1,146 @@
+package config
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPromptReloadInterval is how often a PromptWatcher looks for edited
+// templates.
+const DefaultPromptReloadInterval = 5 * time.Second
+
+// promptExt is the extension of template files, named after their agent, e.g.
+// destination_expert.tmpl. Localized templates are in a subdirectory named
+// after their locale, e.g. es/destination_expert.tmpl.
+const promptExt = ".tmpl"
+
+// PromptWatcher overrides the templates of the registered agents with the ones
+// found in a directory and reloads them when they change, so prompts can be
+// edited without a redeploy. Agents without a file in the directory keep the
+// template they were registered with, including after their file is removed.
+type PromptWatcher struct {
+	dir      string
+	agents   *domain.AgentRegistry
+	defaults map[domain.TemplateID]string
+
+	mu      sync.Mutex
+	applied map[domain.TemplateID]string
+}
+
+// NewPromptWatcher watches dir for the templates of agents. Call Reload once
+// before serving to fail fast on invalid templates.
+func NewPromptWatcher(dir string, agents *domain.AgentRegistry) *PromptWatcher {
+	return &PromptWatcher{dir: dir, agents: agents, defaults: agents.Templates()}
+}
+
+// Reload reads the directory and replaces the templates that changed since the
+// last reload. If any template is unreadable, belongs to no registered agent or
+// fails validation, nothing is replaced.
+func (w *PromptWatcher) Reload() error {
+	overrides, err := readPrompts(w.dir)
+	if err != nil {
+		return fmt.Errorf("prompts: %w", err)
+	}
+	templates := maps.Clone(w.defaults)
+	for id, template := range overrides {
+		if _, ok := w.agents.Definition(id.Agent); !ok {
+			return fmt.Errorf("prompts: %s%s names no registered agent", id, promptExt)
+		}
+		templates[id] = template
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if maps.Equal(w.applied, templates) {
+		return nil
+	}
+	update := maps.Clone(templates)
+	for id := range w.applied {
+		if _, ok := templates[id]; !ok {
+			update[id] = "" // a localization that is neither embedded nor overridden anymore
+		}
+	}
+	changed, err := w.agents.UpdateTemplates(update)
+	if err != nil {
+		return fmt.Errorf("prompts: %w", err)
+	}
+	w.applied = templates
+	for _, id := range changed {
+		if update[id] == "" {
+			log.Printf("prompts: removed %s template", id)
+			continue
+		}
+		log.Printf("prompts: loaded %s template version %s", id, domain.TemplateVersion(update[id]))
+	}
+	return nil
+}
+
+// Watch reloads the templates every interval until ctx is done. Invalid edits
+// are logged and the templates in use are kept.
+func (w *PromptWatcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Reload(); err != nil {
+				log.Printf("%v; keeping the current templates", err)
+			}
+		}
+	}
+}
+
+// readPrompts reads the *.tmpl files of dir and of its locale subdirectories.
+// A missing directory has no templates.
+func readPrompts(dir string) (map[domain.TemplateID]string, error) {
+	templates := make(map[domain.TemplateID]string)
+	if err := readLocalePrompts(dir, domain.DefaultLocale, templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func readLocalePrompts(dir string, locale domain.Locale, templates map[domain.TemplateID]string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && locale == domain.DefaultLocale {
+			sub, ok := domain.ParseLocale(name)
+			if !ok || sub == domain.DefaultLocale || string(sub) != name {
+				return fmt.Errorf("%s/ names no supported locale", name)
+			}
+			if err := readLocalePrompts(filepath.Join(dir, name), sub, templates); err != nil {
+				return err
+			}
+			continue
+		}
+		if entry.IsDir() || filepath.Ext(name) != promptExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		id := domain.TemplateID{Agent: domain.Agent(strings.TrimSuffix(name, promptExt)), Locale: locale}
+		templates[id] = string(data)
+	}
+	return nil
+}
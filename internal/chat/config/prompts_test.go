@@ -0,0 +1,111 @@
+package config
+
+import (
+	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/domain"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptWatcher_Reload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(t *testing.T, name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	definition := func(agents *domain.AgentRegistry, agent domain.Agent) domain.AgentDefinition {
+		def, ok := agents.Definition(agent)
+		require.True(t, ok)
+		return def
+	}
+
+	agents := application.TravelAgents()
+	embedded := definition(agents, domain.DestinationExpert)
+	budget := definition(agents, domain.BudgetPlanner)
+	watcher := NewPromptWatcher(dir, agents)
+	require.NoError(t, watcher.Reload(), "an empty directory keeps the embedded templates")
+	assert.Equal(t, embedded, definition(agents, domain.DestinationExpert))
+
+	t.Run("override", func(t *testing.T) {
+		write(t, "destination_expert.tmpl", "Recommend {{.destination}} to fans of {{.interest}} in {{.language}}.")
+		require.NoError(t, watcher.Reload())
+
+		def := definition(agents, domain.DestinationExpert)
+		assert.Equal(t, "Recommend {{.destination}} to fans of {{.interest}} in {{.language}}.", def.Template)
+		assert.NotEqual(t, embedded.TemplateVersion, def.TemplateVersion)
+		assert.Equal(t, budget, definition(agents, domain.BudgetPlanner))
+	})
+
+	t.Run("invalid edits are rejected as a whole", func(t *testing.T) {
+		current := definition(agents, domain.DestinationExpert)
+		write(t, "budget_planner.tmpl", "Budget for {{.destination}} and {{.preferences}} in {{.language}}.")
+		write(t, "destination_expert.tmpl", "Recommend {{.destinaton}}.")
+		assert.ErrorContains(t, watcher.Reload(), "uses {{.destinaton}}")
+		assert.Equal(t, current, definition(agents, domain.DestinationExpert))
+		assert.Equal(t, budget, definition(agents, domain.BudgetPlanner))
+		require.NoError(t, os.Remove(filepath.Join(dir, "budget_planner.tmpl")))
+	})
+
+	t.Run("unknown agent", func(t *testing.T) {
+		write(t, "destination_expert.tmpl", "Recommend {{.destination}} to fans of {{.interest}} in {{.language}}.")
+		write(t, "visa_advisor.tmpl", "Visas for {{.destination}}.")
+		assert.ErrorContains(t, watcher.Reload(), "visa_advisor.tmpl names no registered agent")
+		require.NoError(t, os.Remove(filepath.Join(dir, "visa_advisor.tmpl")))
+	})
+
+	t.Run("localized", func(t *testing.T) {
+		embeddedSpanish := definition(agents, domain.DestinationExpert).Localize(domain.LocaleSpanish)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "es"), 0o755))
+		write(t, "es/destination_expert.tmpl", "Recomienda {{.destination}} para {{.interest}}.")
+		write(t, "es/visa_advisor.tmpl", "Visados para {{.destination}}.")
+		assert.ErrorContains(t, watcher.Reload(), "es/visa_advisor.tmpl names no registered agent")
+		require.NoError(t, os.Remove(filepath.Join(dir, "es", "visa_advisor.tmpl")))
+
+		require.NoError(t, watcher.Reload())
+		assert.Equal(t, "Recomienda {{.destination}} para {{.interest}}.", definition(agents, domain.DestinationExpert).Localize(domain.LocaleSpanish).Template)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "fr"), 0o755))
+		write(t, "fr/destination_expert.tmpl", "Recommande {{.destination}} pour {{.interest}}.")
+		require.NoError(t, watcher.Reload())
+		assert.Equal(t, "Recommande {{.destination}} pour {{.interest}}.", definition(agents, domain.DestinationExpert).Localize(domain.LocaleFrench).Template)
+
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "fr")))
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "es")))
+		require.NoError(t, watcher.Reload())
+		assert.Equal(t, embeddedSpanish, definition(agents, domain.DestinationExpert).Localize(domain.LocaleSpanish))
+		assert.Equal(t, definition(agents, domain.DestinationExpert).Template, definition(agents, domain.DestinationExpert).Localize(domain.LocaleFrench).Template, "a removed localization falls back to the default template")
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "de"), 0o755))
+		assert.ErrorContains(t, watcher.Reload(), "de/ names no supported locale")
+		require.NoError(t, os.Remove(filepath.Join(dir, "de")))
+	})
+
+	t.Run("removing an override restores the embedded template", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(dir, "destination_expert.tmpl")))
+		require.NoError(t, watcher.Reload())
+		assert.Equal(t, embedded, definition(agents, domain.DestinationExpert))
+	})
+}
+
+func TestPromptWatcher_Watch(t *testing.T) {
+	dir := t.TempDir()
+	agents := application.TravelAgents()
+	watcher := NewPromptWatcher(dir, agents)
+	require.NoError(t, watcher.Reload())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Watch(ctx, 10*time.Millisecond)
+
+	template := "Budget for {{.destination}} and {{.preferences}} in {{.language}}."
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "budget_planner.tmpl"), []byte(template), 0o644))
+	assert.Eventually(t, func() bool {
+		def, _ := agents.Definition(domain.BudgetPlanner)
+		return def.Template == template && def.TemplateVersion == domain.TemplateVersion(template)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"acai_travel/internal/chat/adapters/exchange"
+	_ "embed"
+	"time"
+)
+
+//go:embed exchange_rates.json
+var defaultExchangeRates []byte
+
+// DefaultExchangeRateRefreshInterval is how often a rates file is read again.
+const DefaultExchangeRateRefreshInterval = time.Hour
+
+// LoadExchangeRates reads the exchange-rate table from path, or from the
+// embedded exchange_rates.json when path is empty. Only a table read from path
+// can be refreshed.
+func LoadExchangeRates(path string) (*exchange.Table, error) {
+	if path == "" {
+		return exchange.Parse(defaultExchangeRates)
+	}
+	return exchange.LoadFile(path)
+}
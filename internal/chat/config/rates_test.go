@@ -0,0 +1,20 @@
+package config
+
+import (
+	"acai_travel/internal/chat/domain"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExchangeRates_Embedded(t *testing.T) {
+	rates, err := LoadExchangeRates("")
+	require.NoError(t, err)
+
+	rate, err := rates.Rate(context.Background(), domain.CurrencyUSD, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 0.92, rate.Rate)
+	assert.Equal(t, "2026-10-01", rate.AsOf.Format("2006-01-02"))
+}
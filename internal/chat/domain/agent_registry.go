@@ -0,0 +1,459 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// OutputKind is what an agent answers with.
+type OutputKind string
+
+const (
+	OutputText       OutputKind = "text"       // prose for the user or for the next agent
+	OutputStructured OutputKind = "structured" // JSON decoded into a Go type
+)
+
+// AgentDefinition is everything needed to run an agent with the generic agent
+// runner: its system prompt and the values it needs, which model answers it and
+// how.
+type AgentDefinition struct {
+	Name  Agent
+	Title string // shown in status events and errors, e.g. "LLM 2 (destination expert)"
+	// Template is the system prompt as a text/template, with a {{.key}}
+	// placeholder per injected value.
+	Template string
+	// TemplateVersion identifies the template text in agent sessions; Register
+	// derives it from the text when empty.
+	TemplateVersion string
+	// RequiredKeys are the placeholders the prompt cannot be rendered without.
+	RequiredKeys []string
+	// OptionalKeys are the placeholders that may be rendered empty. Every
+	// placeholder of the template is either required or optional.
+	OptionalKeys []string
+	// DefaultModel answers the agent unless the model configuration assigns
+	// another one.
+	DefaultModel LLMModel
+	Output       OutputKind
+	// Result is a zero value of the Go type a structured agent's answer is
+	// decoded into, e.g. ExtractedIntent{}; its JSON schema is what the model
+	// is asked for.
+	Result any
+	// Streaming sends the answer to the user as the model writes it.
+	Streaming bool
+	// Instruction, when set, is the only user message the agent receives;
+	// otherwise it sees the conversation history.
+	Instruction string
+	// Localized holds the agent's prompt in other languages. Template and
+	// Instruction are written in DefaultLocale and stand in for the locales
+	// without a localization, so they should tell the model to answer in
+	// {{.language}}.
+	Localized map[Locale]Localization
+}
+
+// Localization is an agent's prompt in another language. Its template uses the
+// keys of the agent's default template but may leave out optional ones.
+type Localization struct {
+	Template string
+	// TemplateVersion is derived from Template by Register.
+	TemplateVersion string
+	// Instruction replaces the agent's instruction when set.
+	Instruction string
+}
+
+// Localize returns the definition to run for a conversation in locale: the
+// agent's localized template and instruction when it has them, its default
+// ones otherwise.
+func (d AgentDefinition) Localize(locale Locale) AgentDefinition {
+	loc, ok := d.Localized[locale]
+	if !ok {
+		return d
+	}
+	d.Template = loc.Template
+	d.TemplateVersion = loc.TemplateVersion
+	if loc.Instruction != "" {
+		d.Instruction = loc.Instruction
+	}
+	return d
+}
+
+// parse parses the template. Rendering fails on placeholders without a value
+// instead of shipping "<no value>" to the model.
+func (d AgentDefinition) parse() (*template.Template, error) {
+	return parseTemplate(d.Name, d.Template)
+}
+
+func parseTemplate(agent Agent, text string) (*template.Template, error) {
+	return template.New(string(agent)).Option("missingkey=error").Parse(text)
+}
+
+// Keys returns the template's placeholders in the order they first appear.
+func (d AgentDefinition) Keys() []string {
+	tmpl, err := d.parse()
+	if err != nil {
+		return nil
+	}
+	return templateKeys(tmpl)
+}
+
+func templateKeys(tmpl *template.Template) []string {
+	var keys []string
+	collectKeys(tmpl.Tree.Root, &keys)
+	return keys
+}
+
+// collectKeys appends the map keys node reads from the template's data, i.e.
+// the first name of every {{.key}} field.
+func collectKeys(node parse.Node, keys *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectKeys(child, keys)
+		}
+	case *parse.ActionNode:
+		collectKeys(n.Pipe, keys)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				collectKeys(arg, keys)
+			}
+		}
+	case *parse.FieldNode:
+		if !slices.Contains(*keys, n.Ident[0]) {
+			*keys = append(*keys, n.Ident[0])
+		}
+	case *parse.IfNode:
+		collectKeys(&n.BranchNode, keys)
+	case *parse.RangeNode:
+		collectKeys(&n.BranchNode, keys)
+	case *parse.WithNode:
+		collectKeys(&n.BranchNode, keys)
+	case *parse.BranchNode:
+		collectKeys(n.Pipe, keys)
+		collectKeys(n.List, keys)
+		collectKeys(n.ElseList, keys)
+	}
+}
+
+// Render executes the template with values. Values are inserted as they are,
+// so a value that looks like a placeholder is not substituted again.
+func (d AgentDefinition) Render(values map[string]string) (string, error) {
+	for _, key := range d.RequiredKeys {
+		if values[key] == "" {
+			return "", ErrMissingInjection(key)
+		}
+	}
+	tmpl, err := d.parse()
+	if err != nil {
+		return "", fmt.Errorf("%s template: %w", d.Name, err)
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, values); err != nil {
+		return "", fmt.Errorf("%s template: %w", d.Name, err)
+	}
+	return prompt.String(), nil
+}
+
+// InputKey identifies what an answer was produced from: the template version,
+// the model and the values of the rendered prompt. An answer can be reused
+// while none of them change, so an edited template or a reassigned model
+// reaches ongoing conversations on their next turn.
+func (d AgentDefinition) InputKey(model LLMModel, values map[string]string) string {
+	keys := d.Keys()
+	parts := make([]string, 0, len(keys)+2)
+	parts = append(parts, d.TemplateVersion, string(model))
+	for _, key := range keys {
+		parts = append(parts, values[key])
+	}
+	return strings.Join(parts, "|")
+}
+
+// Requirements lists what the agent needs from its model.
+func (d AgentDefinition) Requirements() []Capability {
+	var needs []Capability
+	switch d.Output {
+	case OutputStructured:
+		needs = append(needs, CapabilityStructuredOutput)
+	default:
+		needs = append(needs, CapabilityChat)
+	}
+	if d.Streaming {
+		needs = append(needs, CapabilityStreaming)
+	}
+	return needs
+}
+
+func (d AgentDefinition) validate() error {
+	if d.Name == "" {
+		return errors.New("agent registry: agent without name")
+	}
+	if d.Template == "" {
+		return fmt.Errorf("agent registry: %s has no template", d.Name)
+	}
+	if d.Output != OutputText && d.Output != OutputStructured {
+		return fmt.Errorf("agent registry: %s has unknown output %q", d.Name, d.Output)
+	}
+	if d.Output == OutputStructured && d.Streaming {
+		return fmt.Errorf("agent registry: %s cannot stream structured output", d.Name)
+	}
+	if (d.Output == OutputStructured) != (d.Result != nil) {
+		return fmt.Errorf("agent registry: %s must have a result type if and only if its output is structured", d.Name)
+	}
+	if err := d.checkTemplate("template", d.Template, true); err != nil {
+		return err
+	}
+	for locale, loc := range d.Localized {
+		if locale == DefaultLocale || locale.Language() == "" {
+			return fmt.Errorf("agent registry: %s is localized for unsupported locale %q", d.Name, locale)
+		}
+		if loc.Template == "" {
+			return fmt.Errorf("agent registry: %s has no %s template", d.Name, locale)
+		}
+		if err := d.checkTemplate(string(locale)+" template", loc.Template, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTemplate checks that text parses and only uses declared keys. Every
+// required key must be used, and every optional one too when allKeys is set.
+func (d AgentDefinition) checkTemplate(label, text string, allKeys bool) error {
+	tmpl, err := parseTemplate(d.Name, text)
+	if err != nil {
+		return fmt.Errorf("agent registry: %s %s: %w", d.Name, label, err)
+	}
+	keys := templateKeys(tmpl)
+	declared := append(slices.Clone(d.RequiredKeys), d.OptionalKeys...)
+	for _, key := range keys {
+		if !slices.Contains(declared, key) {
+			return fmt.Errorf("agent registry: %s %s uses {{.%s}}, which is neither a required nor an optional key", d.Name, label, key)
+		}
+	}
+	used := d.RequiredKeys
+	if allKeys {
+		used = declared
+	}
+	for _, key := range used {
+		if !slices.Contains(keys, key) {
+			return fmt.Errorf("agent registry: %s declares %s, which its %s does not use", d.Name, key, label)
+		}
+	}
+	return nil
+}
+
+// TemplateVersion derives a version ID from a template's text, so every edit
+// of a prompt gets a new one.
+func TemplateVersion(template string) string {
+	sum := sha256.Sum256([]byte(template))
+	return hex.EncodeToString(sum[:6])
+}
+
+// TemplateID names one template of a registered agent: its default template,
+// written in DefaultLocale, or one of its localizations.
+type TemplateID struct {
+	Agent  Agent
+	Locale Locale
+}
+
+func (id TemplateID) String() string {
+	if id.Locale == DefaultLocale {
+		return string(id.Agent)
+	}
+	return string(id.Locale) + "/" + string(id.Agent)
+}
+
+// Injection fills the template of a registered agent.
+type Injection struct {
+	Agent  AgentDefinition
+	Values map[string]string
+}
+
+func (i Injection) ToPrompt(agent Agent) (string, error) {
+	if agent != i.Agent.Name {
+		return "", fmt.Errorf("invalid agent: expected %s, got %s", i.Agent.Name, agent)
+	}
+	return i.Agent.Render(i.Values)
+}
+
+func (i Injection) Inputs() map[string]string {
+	inputs := make(map[string]string, len(i.Values))
+	for key, value := range i.Values {
+		inputs[key] = value
+	}
+	return inputs
+}
+
+// AgentRegistry holds the agents the orchestrator can run. It is safe for
+// concurrent use, so templates can be replaced while runs read them.
+type AgentRegistry struct {
+	mu     sync.RWMutex
+	agents map[Agent]AgentDefinition
+	order  []Agent
+}
+
+func NewAgentRegistry() *AgentRegistry {
+	return &AgentRegistry{agents: make(map[Agent]AgentDefinition)}
+}
+
+// Register adds agents. Names must be unique.
+func (r *AgentRegistry) Register(defs ...AgentDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, def := range defs {
+		if err := def.validate(); err != nil {
+			return err
+		}
+		if _, dup := r.agents[def.Name]; dup {
+			return fmt.Errorf("agent registry: duplicate agent %s", def.Name)
+		}
+		if def.Title == "" {
+			def.Title = string(def.Name)
+		}
+		if def.TemplateVersion == "" {
+			def.TemplateVersion = TemplateVersion(def.Template)
+		}
+		def.Localized = cloneLocalized(def.Localized)
+		r.agents[def.Name] = def
+		r.order = append(r.order, def.Name)
+	}
+	return nil
+}
+
+// UpdateTemplates replaces templates of registered agents and returns the ones
+// that changed. An empty localized template removes the localization. Every
+// new template is validated against its agent's keys first; if one is
+// invalid, none is replaced.
+func (r *AgentRegistry) UpdateTemplates(templates map[TemplateID]string) ([]TemplateID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	updated := make(map[Agent]AgentDefinition, len(templates))
+	var changed []TemplateID
+	for id, template := range templates {
+		def, ok := updated[id.Agent]
+		if !ok {
+			if def, ok = r.agents[id.Agent]; !ok {
+				return nil, fmt.Errorf("agent registry: unknown agent %s", id.Agent)
+			}
+			def.Localized = cloneLocalized(def.Localized)
+		}
+		if id.Locale == DefaultLocale {
+			if def.Template == template {
+				continue
+			}
+			def.Template = template
+			def.TemplateVersion = TemplateVersion(template)
+		} else {
+			loc := def.Localized[id.Locale]
+			if loc.Template == template {
+				continue
+			}
+			if template == "" {
+				delete(def.Localized, id.Locale)
+			} else {
+				loc.Template = template
+				loc.TemplateVersion = TemplateVersion(template)
+				def.Localized[id.Locale] = loc
+			}
+		}
+		updated[id.Agent] = def
+		changed = append(changed, id)
+	}
+	for _, def := range updated {
+		if err := def.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	for agent, def := range updated {
+		r.agents[agent] = def
+	}
+	slices.SortFunc(changed, func(a, b TemplateID) int {
+		if a.Agent != b.Agent {
+			return slices.Index(r.order, a.Agent) - slices.Index(r.order, b.Agent)
+		}
+		return strings.Compare(string(a.Locale), string(b.Locale))
+	})
+	return changed, nil
+}
+
+// cloneLocalized copies localizations, filling in their template versions, so a
+// definition never shares its map with the caller or with another version of
+// itself.
+func cloneLocalized(localized map[Locale]Localization) map[Locale]Localization {
+	out := make(map[Locale]Localization, len(localized))
+	for locale, loc := range localized {
+		if loc.TemplateVersion == "" {
+			loc.TemplateVersion = TemplateVersion(loc.Template)
+		}
+		out[locale] = loc
+	}
+	return out
+}
+
+// Definition returns the definition registered for agent.
+func (r *AgentRegistry) Definition(agent Agent) (AgentDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, ok := r.agents[agent]
+	return def, ok
+}
+
+// Agents returns the registered agents in registration order.
+func (r *AgentRegistry) Agents() []Agent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Agent(nil), r.order...)
+}
+
+// Templates returns the current templates of every registered agent, default
+// and localized.
+func (r *AgentRegistry) Templates() map[TemplateID]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[TemplateID]string, len(r.agents))
+	for agent, def := range r.agents {
+		out[TemplateID{Agent: agent, Locale: DefaultLocale}] = def.Template
+		for locale, loc := range def.Localized {
+			out[TemplateID{Agent: agent, Locale: locale}] = loc.Template
+		}
+	}
+	return out
+}
+
+// Requirements lists what each registered agent needs from its model, for
+// validating model assignments.
+func (r *AgentRegistry) Requirements() map[Agent][]Capability {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[Agent][]Capability, len(r.agents))
+	for agent, def := range r.agents {
+		out[agent] = def.Requirements()
+	}
+	return out
+}
+
+// DefaultModels returns the default model of every agent that has one.
+func (r *AgentRegistry) DefaultModels() map[Agent]LLMModel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[Agent]LLMModel, len(r.agents))
+	for agent, def := range r.agents {
+		if def.DefaultModel != "" {
+			out[agent] = def.DefaultModel
+		}
+	}
+	return out
+}
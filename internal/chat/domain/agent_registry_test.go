@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentDefinition_Render(t *testing.T) {
+	def := AgentDefinition{
+		Name:         "visa_advisor",
+		Template:     "Visas for {{.destination}} ({{.nationality}}); again {{.destination}}.",
+		RequiredKeys: []string{"destination"},
+		OptionalKeys: []string{"nationality"},
+		Output:       OutputText,
+	}
+	assert.Equal(t, []string{"destination", "nationality"}, def.Keys())
+
+	prompt, err := def.Render(map[string]string{"destination": "Peru", "nationality": "Spanish"})
+	require.NoError(t, err)
+	assert.Equal(t, "Visas for Peru (Spanish); again Peru.", prompt)
+
+	_, err = def.Render(map[string]string{"nationality": "Spanish"})
+	assert.EqualError(t, err, "missing required prompt injection: destination")
+
+	_, err = def.Render(map[string]string{"destination": "Peru"})
+	assert.ErrorContains(t, err, `map has no entry for key "nationality"`, "optional keys may be empty, not missing")
+
+	prompt, err = def.Render(map[string]string{"destination": "{{.nationality}}", "nationality": ""})
+	require.NoError(t, err)
+	assert.Equal(t, "Visas for {{.nationality}} (); again {{.nationality}}.", prompt, "values are not substituted again")
+
+	key := def.InputKey("gpt-4", map[string]string{"destination": "Peru"})
+	assert.Equal(t, def.TemplateVersion+"|gpt-4|Peru|", key)
+	assert.NotEqual(t, key, def.InputKey("gpt-4o", map[string]string{"destination": "Peru"}), "another model gives another answer")
+	edited := def
+	edited.TemplateVersion = TemplateVersion("Visas for {{.destination}}")
+	assert.NotEqual(t, key, edited.InputKey("gpt-4", map[string]string{"destination": "Peru"}), "another template gives another answer")
+
+	_, err = Injection{Agent: def}.ToPrompt(BudgetPlanner)
+	assert.Error(t, err, "an injection only renders its own agent's prompt")
+}
+
+func TestAgentRegistry(t *testing.T) {
+	registry := NewAgentRegistry()
+	require.NoError(t, registry.Register(
+		AgentDefinition{Name: "extractor", Template: "Extract", Output: OutputStructured, Result: ExtractedIntent{}},
+		AgentDefinition{Name: "writer", Title: "Writer", Template: "Write about {{.destination}}", RequiredKeys: []string{"destination"}, Output: OutputText, Streaming: true, DefaultModel: "gpt-4"},
+	))
+
+	assert.Equal(t, []Agent{"extractor", "writer"}, registry.Agents())
+	assert.Equal(t, map[Agent][]Capability{
+		"extractor": {CapabilityStructuredOutput},
+		"writer":    {CapabilityChat, CapabilityStreaming},
+	}, registry.Requirements())
+	assert.Equal(t, map[Agent]LLMModel{"writer": "gpt-4"}, registry.DefaultModels())
+
+	def, ok := registry.Definition("extractor")
+	require.True(t, ok)
+	assert.Equal(t, "extractor", def.Title, "the name is the default title")
+	assert.Equal(t, TemplateVersion("Extract"), def.TemplateVersion)
+
+	changed, err := registry.UpdateTemplates(map[TemplateID]string{
+		{Agent: "extractor", Locale: DefaultLocale}: "Extract",
+		{Agent: "writer", Locale: DefaultLocale}:    "Write on {{.destination}}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []TemplateID{{Agent: "writer", Locale: DefaultLocale}}, changed)
+	def, _ = registry.Definition("writer")
+	assert.Equal(t, TemplateVersion("Write on {{.destination}}"), def.TemplateVersion)
+	_, err = registry.UpdateTemplates(map[TemplateID]string{{Agent: "reader", Locale: DefaultLocale}: "Read"})
+	assert.ErrorContains(t, err, "unknown agent reader")
+
+	tests := []struct {
+		name string
+		def  AgentDefinition
+		want string
+	}{
+		{"duplicate", AgentDefinition{Name: "writer", Template: "t", Output: OutputText}, "duplicate agent writer"},
+		{"no name", AgentDefinition{Template: "t", Output: OutputText}, "agent without name"},
+		{"no template", AgentDefinition{Name: "a", Output: OutputText}, "a has no template"},
+		{"unknown output", AgentDefinition{Name: "a", Template: "t"}, `a has unknown output ""`},
+		{"streamed structure", AgentDefinition{Name: "a", Template: "t", Output: OutputStructured, Streaming: true}, "cannot stream structured output"},
+		{"structure without result", AgentDefinition{Name: "a", Template: "t", Output: OutputStructured}, "a must have a result type if and only if its output is structured"},
+		{"text with result", AgentDefinition{Name: "a", Template: "t", Output: OutputText, Result: ExtractedIntent{}}, "a must have a result type"},
+		{"unused key", AgentDefinition{Name: "a", Template: "{{.x}}", RequiredKeys: []string{"x"}, OptionalKeys: []string{"y"}, Output: OutputText}, "a declares y, which its template does not use"},
+		{"undeclared key", AgentDefinition{Name: "a", Template: "{{.x}} {{if .y}}{{.z}}{{end}}", RequiredKeys: []string{"x", "y"}, Output: OutputText}, "uses {{.z}}, which is neither"},
+		{"invalid template", AgentDefinition{Name: "a", Template: "{{.x", Output: OutputText}, "a template:"},
+		{"legacy placeholder", AgentDefinition{Name: "a", Template: "{{x}}", Output: OutputText}, "a template:"},
+		{"unsupported locale", AgentDefinition{Name: "a", Template: "t", Output: OutputText, Localized: map[Locale]Localization{"de": {Template: "t"}}}, `a is localized for unsupported locale "de"`},
+		{"localized key", AgentDefinition{Name: "a", Template: "{{.x}}", RequiredKeys: []string{"x"}, Output: OutputText, Localized: map[Locale]Localization{LocaleSpanish: {Template: "{{.y}}"}}}, "a es template uses {{.y}}"},
+		{"localized required key", AgentDefinition{Name: "a", Template: "{{.x}}", RequiredKeys: []string{"x"}, Output: OutputText, Localized: map[Locale]Localization{LocaleSpanish: {Template: "x"}}}, "a declares x, which its es template does not use"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, registry.Register(tt.def), tt.want)
+		})
+	}
+}
+
+func TestAgentDefinition_Localize(t *testing.T) {
+	registry := NewAgentRegistry()
+	require.NoError(t, registry.Register(AgentDefinition{
+		Name:         "writer",
+		Template:     "Write about {{.destination}} in {{.language}}.",
+		RequiredKeys: []string{"destination"},
+		OptionalKeys: []string{"language"},
+		Output:       OutputText,
+		Instruction:  "Write",
+		Localized: map[Locale]Localization{
+			LocaleSpanish: {Template: "Escribe sobre {{.destination}}.", Instruction: "Escribe"},
+		},
+	}))
+	def, _ := registry.Definition("writer")
+
+	spanish := def.Localize(LocaleSpanish)
+	assert.Equal(t, "Escribe sobre {{.destination}}.", spanish.Template)
+	assert.Equal(t, TemplateVersion(spanish.Template), spanish.TemplateVersion)
+	assert.Equal(t, "Escribe", spanish.Instruction)
+	assert.Equal(t, []string{"destination"}, spanish.Keys(), "a localization may leave out optional keys")
+	assert.Equal(t, def, def.Localize(LocaleFrench), "locales without a localization use the default template")
+
+	changed, err := registry.UpdateTemplates(map[TemplateID]string{
+		{Agent: "writer", Locale: LocaleSpanish}: "",
+		{Agent: "writer", Locale: LocaleFrench}:  "Écris sur {{.destination}}.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []TemplateID{{Agent: "writer", Locale: LocaleSpanish}, {Agent: "writer", Locale: LocaleFrench}}, changed)
+	def, _ = registry.Definition("writer")
+	assert.Equal(t, def.Template, def.Localize(LocaleSpanish).Template, "an empty template removes the localization")
+	assert.Equal(t, "Écris sur {{.destination}}.", def.Localize(LocaleFrench).Template)
+	assert.Equal(t, map[TemplateID]string{
+		{Agent: "writer", Locale: DefaultLocale}: "Write about {{.destination}} in {{.language}}.",
+		{Agent: "writer", Locale: LocaleFrench}:  "Écris sur {{.destination}}.",
+	}, registry.Templates())
+	assert.Equal(t, "fr/writer", TemplateID{Agent: "writer", Locale: LocaleFrench}.String())
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -20,3 +22,57 @@ func NewAgentSessionFromInjection(agent Agent, userID uuid.UUID, injection Promp
 
 	return chat, nil
 }
+
+// AgentSession is the record of one agent call made while answering a user turn:
+// what the agent was given, the full chat it saw (including the rendered system
+// prompt) and what it answered.
+type AgentSession struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	TurnID         uuid.UUID // ID of the user message that triggered the call
+	Agent          Agent
+	// TemplateVersion identifies the template the agent's system prompt was
+	// rendered from.
+	TemplateVersion string
+	Inputs          map[string]string
+	Chat            *Chat
+	Output          string
+	Error           string
+	StartedAt       time.Time
+	FinishedAt      time.Time
+}
+
+// NewAgentSession starts the record of an agent call for the given turn.
+func NewAgentSession(conversationID, turnID uuid.UUID, agent Agent, inputs map[string]string) *AgentSession {
+	return &AgentSession{
+		ID:             uuid.New(),
+		ConversationID: conversationID,
+		TurnID:         turnID,
+		Agent:          agent,
+		Inputs:         inputs,
+		StartedAt:      time.Now().UTC(),
+	}
+}
+
+// Finish completes the record with the chat the agent saw, its output and the error, if any.
+func (s *AgentSession) Finish(chat *Chat, output string, err error) {
+	s.Chat = chat
+	s.Output = output
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.FinishedAt = time.Now().UTC()
+}
+
+// SystemPrompt returns the rendered system prompt the agent received, if any.
+func (s *AgentSession) SystemPrompt() string {
+	if s.Chat == nil {
+		return ""
+	}
+	for _, m := range s.Chat.Messages {
+		if m.Sender == SenderSystem {
+			return m.Content
+		}
+	}
+	return ""
+}
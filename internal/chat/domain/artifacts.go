@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Artifact is the answer of a specialist agent as handed to the agents that
+// depend on it. Each artifact is rendered as its own delimited section so the
+// synthesizer can tell the specialists' input apart from its instructions.
+type Artifact interface {
+	// Source is the agent that produced the artifact.
+	Source() Agent
+	// Tag names the artifact's section, e.g. "destination_advice".
+	Tag() string
+	// Content is the body of the section.
+	Content() string
+}
+
+// DestinationAdvice is the destination expert's recommended places.
+type DestinationAdvice struct {
+	Recommendations string
+}
+
+func (DestinationAdvice) Source() Agent     { return DestinationExpert }
+func (DestinationAdvice) Tag() string       { return "destination_advice" }
+func (a DestinationAdvice) Content() string { return a.Recommendations }
+
+// BudgetPlan is the budget planner's validated cost estimate per destination,
+// in USD, with the totals and budget categories computed by NewBudgetPlan.
+type BudgetPlan struct {
+	Destinations []DestinationBudget `json:"destinations"`
+	// Rate converts the plan's amounts to the user's currency, if it is not
+	// USD; each amount is then rendered in both.
+	Rate *ExchangeRate `json:"-"`
+}
+
+func (BudgetPlan) Source() Agent { return BudgetPlanner }
+func (BudgetPlan) Tag() string   { return "budget_plan" }
+func (p BudgetPlan) Content() string {
+	if p.Rate == nil || len(p.Destinations) == 0 {
+		return p.estimate()
+	}
+	return fmt.Sprintf("%s\n\nAmounts in %s converted at %s, as of %s.", strings.TrimSpace(p.estimate()), p.Rate.To, p.Rate, p.Rate.AsOf.Format(time.DateOnly))
+}
+
+// Convert returns the plan with its amounts also given in rate's currency.
+// rate's From must be USD.
+func (p BudgetPlan) Convert(rate ExchangeRate) BudgetPlan {
+	p.Rate = &rate
+	return p
+}
+
+// AgentNote is the answer of a specialist without an artifact type of its own.
+type AgentNote struct {
+	Agent Agent
+	Text  string
+}
+
+func (n AgentNote) Source() Agent   { return n.Agent }
+func (n AgentNote) Tag() string     { return string(n.Agent) }
+func (n AgentNote) Content() string { return n.Text }
+
+// NewArtifact wraps an agent's answer in the artifact type the agent produces.
+// The budget planner's answer is its plan as JSON; an answer recorded before
+// budgets were structured is kept as a note.
+func NewArtifact(agent Agent, answer string) Artifact {
+	switch agent {
+	case DestinationExpert:
+		return DestinationAdvice{Recommendations: answer}
+	case BudgetPlanner:
+		if plan, err := ParseBudgetPlan(answer); err == nil {
+			return plan
+		}
+	}
+	return AgentNote{Agent: agent, Text: answer}
+}
+
+// RenderArtifacts renders each artifact between <tag> and </tag> lines, in
+// order. Artifacts without content are left out.
+func RenderArtifacts(artifacts []Artifact) string {
+	var sections []string
+	for _, a := range artifacts {
+		content := strings.TrimSpace(a.Content())
+		if content == "" {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("<%s>\n%s\n</%s>", a.Tag(), content, a.Tag()))
+	}
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewArtifact(t *testing.T) {
+	assert.Equal(t, DestinationAdvice{Recommendations: "Cusco"}, NewArtifact(DestinationExpert, "Cusco"))
+	assert.Equal(t, BudgetPlan{Destinations: []DestinationBudget{{DestinationEstimate: DestinationEstimate{Destination: "Peru"}, Total: 1800}}},
+		NewArtifact(BudgetPlanner, `{"destinations":[{"destination":"Peru","total":1800}]}`))
+	assert.Equal(t, AgentNote{Agent: BudgetPlanner, Text: "~$1,800"}, NewArtifact(BudgetPlanner, "~$1,800"), "an answer from before budgets were structured")
+	assert.Equal(t, AgentNote{Agent: "visa_advisor", Text: "No visa needed"}, NewArtifact("visa_advisor", "No visa needed"))
+}
+
+func TestRenderArtifacts(t *testing.T) {
+	rendered := RenderArtifacts([]Artifact{
+		DestinationAdvice{Recommendations: "1. **Cusco**\n"},
+		BudgetPlan{},
+		AgentNote{Agent: "visa_advisor", Text: "No visa needed"},
+	})
+	assert.Equal(t, "<destination_advice>\n1. **Cusco**\n</destination_advice>\n\n<visa_advisor>\nNo visa needed\n</visa_advisor>", rendered)
+}
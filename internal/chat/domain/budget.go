@@ -0,0 +1,226 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CostCategory groups the line items of a destination's budget.
+type CostCategory string
+
+const (
+	CostFlights       CostCategory = "flights"
+	CostAccommodation CostCategory = "accommodation"
+	CostFood          CostCategory = "food"
+	CostActivities    CostCategory = "activities"
+	CostTransport     CostCategory = "transport"
+	CostOther         CostCategory = "other"
+)
+
+// costCategoryNames are the labels of the budget breakdown.
+var costCategoryNames = map[CostCategory]string{
+	CostFlights:       "Flights",
+	CostAccommodation: "Accommodation",
+	CostFood:          "Food",
+	CostActivities:    "Activities",
+	CostTransport:     "Local transport",
+	CostOther:         "Other",
+}
+
+// BudgetLineItem is one cost of a trip, for the whole stay and every traveler.
+type BudgetLineItem struct {
+	Category    CostCategory `json:"category" jsonschema:"enum=flights,enum=accommodation,enum=food,enum=activities,enum=transport,enum=other"`
+	Description string       `json:"description" jsonschema:"description=what the amount pays for, e.g. round-trip flights from Madrid"`
+	Amount      float64      `json:"amount" jsonschema:"description=cost in USD for the whole stay and every traveler"`
+}
+
+// DestinationEstimate is the budget planner's estimate for one destination.
+type DestinationEstimate struct {
+	Destination    string           `json:"destination"`
+	Currency       Currency         `json:"currency" jsonschema:"enum=USD"`
+	Nights         int              `json:"nights" jsonschema:"description=nights the estimate covers"`
+	Travelers      int              `json:"travelers" jsonschema:"description=travelers the estimate covers"`
+	Items          []BudgetLineItem `json:"items"`
+	BestTimeToBook string           `json:"bestTimeToBook"`
+	Alternatives   string           `json:"alternatives" jsonschema:"description=cheaper alternatives, or empty"`
+}
+
+// BudgetEstimate is the typed structured output of the budget planner. It has
+// no totals: NewBudgetPlan adds them up in Go, so they always match the items.
+type BudgetEstimate struct {
+	Destinations []DestinationEstimate `json:"destinations"`
+}
+
+// BudgetCategory is how expensive a trip is.
+type BudgetCategory string
+
+const (
+	BudgetLow    BudgetCategory = "Low"
+	BudgetMedium BudgetCategory = "Medium"
+	BudgetHigh   BudgetCategory = "High"
+)
+
+// BudgetRules assign the budget category of a trip from its daily cost per
+// traveler in USD.
+type BudgetRules struct {
+	// LowBelow is the daily cost per traveler under which a trip is Low.
+	LowBelow float64 `json:"lowBelow"`
+	// HighFrom is the daily cost per traveler from which a trip is High.
+	HighFrom float64 `json:"highFrom"`
+}
+
+// DefaultBudgetRules are the thresholds used when none are configured.
+var DefaultBudgetRules = BudgetRules{LowBelow: 100, HighFrom: 250}
+
+// Validate checks that the thresholds are positive and in order.
+func (r BudgetRules) Validate() error {
+	if r.LowBelow <= 0 || r.HighFrom < r.LowBelow {
+		return fmt.Errorf("budget rules: need 0 < lowBelow <= highFrom, got lowBelow %v and highFrom %v", r.LowBelow, r.HighFrom)
+	}
+	return nil
+}
+
+// Category returns the category of a trip costing daily USD per traveler and
+// night.
+func (r BudgetRules) Category(daily float64) BudgetCategory {
+	switch {
+	case daily < r.LowBelow:
+		return BudgetLow
+	case daily >= r.HighFrom:
+		return BudgetHigh
+	}
+	return BudgetMedium
+}
+
+// ErrInvalidBudget is returned for an estimate whose numbers cannot be used.
+var ErrInvalidBudget = errors.New("invalid budget estimate")
+
+// DestinationBudget is a destination's validated estimate with the totals
+// computed from its items.
+type DestinationBudget struct {
+	DestinationEstimate
+	Total float64 `json:"total"`
+	// DailyCostPerTraveler is Total per night and traveler.
+	DailyCostPerTraveler float64        `json:"dailyCostPerTraveler"`
+	Category             BudgetCategory `json:"category"`
+}
+
+// NewBudgetPlan validates the budget planner's estimate, rounds its amounts to
+// whole dollars, adds up each destination's total and assigns its category
+// with rules.
+func NewBudgetPlan(estimate BudgetEstimate, rules BudgetRules) (BudgetPlan, error) {
+	if len(estimate.Destinations) == 0 {
+		return BudgetPlan{}, fmt.Errorf("%w: no destinations", ErrInvalidBudget)
+	}
+	plan := BudgetPlan{Destinations: make([]DestinationBudget, len(estimate.Destinations))}
+	for i, d := range estimate.Destinations {
+		budget, err := newDestinationBudget(d, rules)
+		if err != nil {
+			return BudgetPlan{}, fmt.Errorf("%w: destination %d (%s): %v", ErrInvalidBudget, i+1, d.Destination, err)
+		}
+		plan.Destinations[i] = budget
+	}
+	return plan, nil
+}
+
+func newDestinationBudget(d DestinationEstimate, rules BudgetRules) (DestinationBudget, error) {
+	d.Destination = strings.TrimSpace(d.Destination)
+	switch {
+	case d.Destination == "":
+		return DestinationBudget{}, errors.New("no destination name")
+	case d.Currency != CurrencyUSD:
+		return DestinationBudget{}, fmt.Errorf("amounts in %q instead of USD", d.Currency)
+	case d.Nights < 1:
+		return DestinationBudget{}, fmt.Errorf("%d nights", d.Nights)
+	case d.Travelers < 1:
+		return DestinationBudget{}, fmt.Errorf("%d travelers", d.Travelers)
+	case len(d.Items) == 0:
+		return DestinationBudget{}, errors.New("no line items")
+	}
+
+	items := make([]BudgetLineItem, len(d.Items))
+	var total float64
+	for i, item := range d.Items {
+		if _, ok := costCategoryNames[item.Category]; !ok {
+			return DestinationBudget{}, fmt.Errorf("unknown cost category %q", item.Category)
+		}
+		if item.Amount < 0 || math.IsNaN(item.Amount) || math.IsInf(item.Amount, 0) {
+			return DestinationBudget{}, fmt.Errorf("%s amount %v", item.Category, item.Amount)
+		}
+		item.Amount = math.Round(item.Amount) // the breakdown shows whole dollars, so the total adds up
+		items[i] = item
+		total += item.Amount
+	}
+	if total == 0 {
+		return DestinationBudget{}, errors.New("total is zero")
+	}
+	d.Items = items
+
+	daily := total / float64(d.Nights*d.Travelers)
+	return DestinationBudget{
+		DestinationEstimate:  d,
+		Total:                total,
+		DailyCostPerTraveler: daily,
+		Category:             rules.Category(daily),
+	}, nil
+}
+
+// ParseBudgetPlan reads a plan written as JSON.
+func ParseBudgetPlan(data string) (BudgetPlan, error) {
+	var plan BudgetPlan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return BudgetPlan{}, err
+	}
+	if len(plan.Destinations) == 0 {
+		return BudgetPlan{}, fmt.Errorf("%w: no destinations", ErrInvalidBudget)
+	}
+	return plan, nil
+}
+
+// estimate renders the plan in the budget planner's output format: a numbered,
+// bold heading per destination, e.g. "1. **Peru**", followed by its total,
+// budget category, breakdown and booking tips.
+func (p BudgetPlan) estimate() string {
+	var b strings.Builder
+	for i, d := range p.Destinations {
+		fmt.Fprintf(&b, "%d. **%s**\n", i+1, d.Destination)
+		fmt.Fprintf(&b, "   Estimated Budget: ~%s USD%s for %s, %s\n", formatDollars(d.Total), p.converted(d.Total), plural(d.Travelers, "traveler"), plural(d.Nights, "night"))
+		fmt.Fprintf(&b, "   Budget Category: %s (~%s%s per traveler per night)\n", d.Category, formatDollars(d.DailyCostPerTraveler), p.converted(d.DailyCostPerTraveler))
+		breakdown := make([]string, len(d.Items))
+		for j, item := range d.Items {
+			label := costCategoryNames[item.Category]
+			if item.Description != "" {
+				label += " (" + item.Description + ")"
+			}
+			breakdown[j] = label + ": " + formatDollars(item.Amount) + p.converted(item.Amount)
+		}
+		fmt.Fprintf(&b, "   Breakdown: %s\n", strings.Join(breakdown, ", "))
+		if d.BestTimeToBook != "" {
+			fmt.Fprintf(&b, "   Best time to book: %s\n", d.BestTimeToBook)
+		}
+		if d.Alternatives != "" {
+			fmt.Fprintf(&b, "   Alternatives: %s\n", d.Alternatives)
+		}
+	}
+	return b.String()
+}
+
+// converted writes a USD amount of the plan in the currency it was converted
+// to, e.g. " (≈ 1,656 EUR)", or nothing when it was not converted.
+func (p BudgetPlan) converted(amount float64) string {
+	if p.Rate == nil {
+		return ""
+	}
+	return " (≈ " + FormatAmount(p.Rate.Convert(amount), p.Rate.To) + ")"
+}
+
+// plural writes a count of things, e.g. "1 night" or "7 nights".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
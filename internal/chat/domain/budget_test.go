@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peruEstimate is a budget planner answer whose amounts are not whole dollars.
+func peruEstimate() BudgetEstimate {
+	return BudgetEstimate{Destinations: []DestinationEstimate{{
+		Destination: "Peru",
+		Currency:    CurrencyUSD,
+		Nights:      7,
+		Travelers:   2,
+		Items: []BudgetLineItem{
+			{Category: CostFlights, Description: "round trip", Amount: 899.6},
+			{Category: CostAccommodation, Amount: 600},
+			{Category: CostFood, Amount: 300.2},
+		},
+		BestTimeToBook: "two months ahead",
+	}}}
+}
+
+func TestNewBudgetPlan_AddsUpTheItems(t *testing.T) {
+	plan, err := NewBudgetPlan(peruEstimate(), DefaultBudgetRules)
+	require.NoError(t, err)
+
+	peru := plan.Destinations[0]
+	assert.Equal(t, 1800.0, peru.Total)
+	assert.InDelta(t, 128.57, peru.DailyCostPerTraveler, 0.01)
+	assert.Equal(t, BudgetMedium, peru.Category)
+	assert.Equal(t, "1. **Peru**\n"+
+		"   Estimated Budget: ~$1,800 USD for 2 travelers, 7 nights\n"+
+		"   Budget Category: Medium (~$129 per traveler per night)\n"+
+		"   Breakdown: Flights (round trip): $900, Accommodation: $600, Food: $300\n"+
+		"   Best time to book: two months ahead\n", plan.Content())
+}
+
+func TestNewBudgetPlan_RejectsUnusableEstimates(t *testing.T) {
+	for name, spoil := range map[string]func(*DestinationEstimate){
+		"other currency":     func(d *DestinationEstimate) { d.Currency = "EUR" },
+		"no nights":          func(d *DestinationEstimate) { d.Nights = 0 },
+		"no travelers":       func(d *DestinationEstimate) { d.Travelers = 0 },
+		"no items":           func(d *DestinationEstimate) { d.Items = nil },
+		"negative amount":    func(d *DestinationEstimate) { d.Items[0].Amount = -900 },
+		"unknown category":   func(d *DestinationEstimate) { d.Items[0].Category = "souvenirs" },
+		"no destination":     func(d *DestinationEstimate) { d.Destination = " " },
+		"everything is free": func(d *DestinationEstimate) { d.Items = []BudgetLineItem{{Category: CostOther}} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			estimate := peruEstimate()
+			spoil(&estimate.Destinations[0])
+			_, err := NewBudgetPlan(estimate, DefaultBudgetRules)
+			assert.True(t, errors.Is(err, ErrInvalidBudget), err)
+		})
+	}
+
+	_, err := NewBudgetPlan(BudgetEstimate{}, DefaultBudgetRules)
+	assert.ErrorIs(t, err, ErrInvalidBudget)
+}
+
+func TestBudgetRules_Category(t *testing.T) {
+	rules := BudgetRules{LowBelow: 100, HighFrom: 250}
+	assert.Equal(t, BudgetLow, rules.Category(99.9))
+	assert.Equal(t, BudgetMedium, rules.Category(100))
+	assert.Equal(t, BudgetMedium, rules.Category(249))
+	assert.Equal(t, BudgetHigh, rules.Category(250))
+
+	assert.NoError(t, rules.Validate())
+	assert.Error(t, BudgetRules{LowBelow: 0, HighFrom: 250}.Validate())
+	assert.Error(t, BudgetRules{LowBelow: 300, HighFrom: 250}.Validate())
+}
+
+func TestParseBudgetPlan(t *testing.T) {
+	plan, err := NewBudgetPlan(peruEstimate(), BudgetRules{LowBelow: 150, HighFrom: 400})
+	require.NoError(t, err)
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+
+	parsed, err := ParseBudgetPlan(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, plan, parsed)
+	assert.Equal(t, BudgetLow, parsed.Destinations[0].Category, "the category is kept, not assigned again")
+
+	_, err = ParseBudgetPlan("1. **Peru** ~$1,800 USD")
+	assert.Error(t, err)
+}
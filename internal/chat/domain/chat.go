@@ -31,6 +31,27 @@ func NewChat(userID uuid.UUID) *Chat {
 	}
 }
 
+// NewChatWithID creates a new chat instance with a caller-provided ID, used when
+// the conversation ID is chosen by the client.
+func NewChatWithID(id, userID uuid.UUID) *Chat {
+	chat := NewChat(userID)
+	chat.ID = id
+	return chat
+}
+
+// History returns the non-system messages of the chat, which is the part of a
+// conversation that can be replayed into a new agent session.
+func (c *Chat) History() []Message {
+	history := make([]Message, 0, len(c.Messages))
+	for _, m := range c.Messages {
+		if m.Sender == SenderSystem {
+			continue
+		}
+		history = append(history, m)
+	}
+	return history
+}
+
 // AddMessage validates and adds the message to the chat in place.
 // Returns an error if the message doesn't belong to the chat or if the first message is not system-generated.
 func (c *Chat) AddMessage(message Message) error {
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// RequiredIntentFields are the trip details the agents cannot work without.
+// Without a destination there is nothing to recommend or to budget for.
+var RequiredIntentFields = []IntentField{FieldDestinations}
+
+// fieldQuestions are asked when the extractor did not write a follow-up question.
+var fieldQuestions = map[Locale]map[IntentField]string{
+	LocaleEnglish: {
+		FieldDestinations: "Which destination or destinations would you like to travel to?",
+		FieldPreferences:  "What kind of accommodation and budget do you prefer?",
+		FieldInterest:     "What would you like to do during the trip?",
+	},
+	LocaleSpanish: {
+		FieldDestinations: "¿A qué destino o destinos te gustaría viajar?",
+		FieldPreferences:  "¿Qué tipo de alojamiento y presupuesto prefieres?",
+		FieldInterest:     "¿Qué te gustaría hacer durante el viaje?",
+	},
+	LocalePortuguese: {
+		FieldDestinations: "Para qual destino ou destinos você gostaria de viajar?",
+		FieldPreferences:  "Que tipo de hospedagem e orçamento você prefere?",
+		FieldInterest:     "O que você gostaria de fazer durante a viagem?",
+	},
+	LocaleFrench: {
+		FieldDestinations: "Dans quelle destination ou quelles destinations aimeriez-vous voyager ?",
+		FieldPreferences:  "Quel type d'hébergement et quel budget préférez-vous ?",
+		FieldInterest:     "Qu'aimeriez-vous faire pendant le voyage ?",
+	},
+}
+
+// Clarification is a follow-up question a run stopped on because required trip
+// details are missing or unclear. The run resumes on the user's answer.
+type Clarification struct {
+	Fields   []IntentField `json:"fields"`
+	Question string        `json:"question"`
+	AskedAt  time.Time     `json:"askedAt"`
+}
+
+// NewClarification asks about fields, using question when the extractor wrote
+// one and a generic question per field, in locale, otherwise.
+func NewClarification(fields []IntentField, question string, locale Locale) Clarification {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		generic := localized(fieldQuestions, locale)
+		questions := make([]string, 0, len(fields))
+		for _, field := range fields {
+			questions = append(questions, generic[field])
+		}
+		question = strings.Join(questions, " ")
+	}
+	return Clarification{
+		Fields:   append([]IntentField(nil), fields...),
+		Question: question,
+		AskedAt:  time.Now().UTC(),
+	}
+}
+
+// UnclearFields returns the required fields that are still empty, or whose
+// latest change the extractor was not confident about, once extracted was
+// merged into the intent.
+func (t TravelIntent) UnclearFields(extracted ExtractedIntent) []IntentField {
+	var unclear []IntentField
+	for _, field := range RequiredIntentFields {
+		f := extracted.Field(field)
+		unsure := f.Confidence == ConfidenceLow && f.Op != OpKeep
+		if len(t.Values(field)) == 0 || unsure {
+			unclear = append(unclear, field)
+		}
+	}
+	return unclear
+}
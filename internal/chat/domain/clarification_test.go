@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTravelIntent_UnclearFields(t *testing.T) {
+	peru := TravelIntent{Destinations: []string{"Peru"}}
+
+	tests := []struct {
+		name      string
+		intent    TravelIntent
+		extracted ExtractedIntent
+		want      []IntentField
+	}{
+		{
+			name:   "missing destination",
+			intent: TravelIntent{Interest: []string{"hiking"}},
+			want:   []IntentField{FieldDestinations},
+		},
+		{
+			name:      "confident destination",
+			intent:    peru,
+			extracted: ExtractedIntent{Destinations: ExtractedField{Op: OpAdd, Values: []string{"Peru"}, Confidence: ConfidenceHigh}},
+		},
+		{
+			name:      "unsure destination",
+			intent:    peru,
+			extracted: ExtractedIntent{Destinations: ExtractedField{Op: OpReplace, Values: []string{"Peru"}, Confidence: ConfidenceLow}},
+			want:      []IntentField{FieldDestinations},
+		},
+		{
+			name:      "kept destination ignores confidence",
+			intent:    peru,
+			extracted: ExtractedIntent{Destinations: ExtractedField{Op: OpKeep, Confidence: ConfidenceLow}},
+		},
+		{
+			name:      "optional fields are never unclear",
+			intent:    peru,
+			extracted: ExtractedIntent{Interest: ExtractedField{Op: OpAdd, Values: []string{"food?"}, Confidence: ConfidenceLow}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.intent.UnclearFields(tt.extracted))
+		})
+	}
+}
+
+func TestNewClarification(t *testing.T) {
+	fields := []IntentField{FieldDestinations}
+
+	asked := NewClarification(fields, "  Which part of Peru?  ", LocaleSpanish)
+	assert.Equal(t, "Which part of Peru?", asked.Question)
+	assert.Equal(t, fields, asked.Fields)
+	assert.False(t, asked.AskedAt.IsZero())
+
+	generic := NewClarification(fields, "", LocaleSpanish)
+	assert.Equal(t, "¿A qué destino o destinos te gustaría viajar?", generic.Question)
+	generic = NewClarification(fields, "", "de")
+	assert.Equal(t, fieldQuestions[LocaleEnglish][FieldDestinations], generic.Question, "unsupported locales get English")
+}
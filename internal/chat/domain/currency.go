@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Currency is an ISO 4217 currency code, e.g. "EUR".
+type Currency string
+
+// CurrencyUSD is the currency the agents estimate prices in. Amounts are
+// converted to the user's currency in Go, not by the model.
+const CurrencyUSD Currency = "USD"
+
+// ErrUnknownCurrency is returned for a currency an exchange-rate provider has no
+// rate for.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ParseCurrency reads a currency code such as "eur". It reports false for
+// anything but three letters.
+func ParseCurrency(code string) (Currency, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return Currency(code), true
+}
+
+// ExchangeRate is how many units of To one unit of From buys.
+type ExchangeRate struct {
+	From Currency  `json:"from"`
+	To   Currency  `json:"to"`
+	Rate float64   `json:"rate"`
+	AsOf time.Time `json:"asOf"` // when the rate was published
+}
+
+// Convert converts an amount of From into To.
+func (r ExchangeRate) Convert(amount float64) float64 {
+	return amount * r.Rate
+}
+
+func (r ExchangeRate) String() string {
+	return fmt.Sprintf("1 %s = %s %s", r.From, strconv.FormatFloat(r.Rate, 'f', -1, 64), r.To)
+}
+
+// ExchangeRateProvider gives the rate between two currencies.
+type ExchangeRateProvider interface {
+	// Rate returns the rate from one currency to another, or an error wrapping
+	// ErrUnknownCurrency when either currency has no rate.
+	Rate(ctx context.Context, from, to Currency) (ExchangeRate, error)
+}
+
+// FormatAmount writes an amount rounded to whole units with thousands
+// separators, followed by its currency, e.g. "1,656 EUR".
+func FormatAmount(amount float64, currency Currency) string {
+	return groupThousands(amount) + " " + string(currency)
+}
+
+// formatDollars writes a USD amount as the agents quote prices, e.g. "$1,800".
+func formatDollars(amount float64) string {
+	return "$" + groupThousands(amount)
+}
+
+// groupThousands writes an amount rounded to whole units with thousands
+// separators.
+func groupThousands(amount float64) string {
+	digits := strconv.FormatInt(int64(math.Round(math.Abs(amount))), 10)
+	var b strings.Builder
+	if amount <= -0.5 {
+		b.WriteByte('-')
+	}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCurrency(t *testing.T) {
+	for code, want := range map[string]Currency{"eur": "EUR", " MXN ": "MXN"} {
+		got, ok := ParseCurrency(code)
+		assert.True(t, ok, code)
+		assert.Equal(t, want, got)
+	}
+	for _, code := range []string{"", "€", "EURO", "E1R"} {
+		_, ok := ParseCurrency(code)
+		assert.False(t, ok, code)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "1,656 EUR", FormatAmount(1656.4, "EUR"))
+	assert.Equal(t, "30,600 MXN", FormatAmount(30599.5, "MXN"))
+	assert.Equal(t, "1,000,000 JPY", FormatAmount(999999.9, "JPY"))
+	assert.Equal(t, "900 EUR", FormatAmount(900, "EUR"))
+	assert.Equal(t, "-12 EUR", FormatAmount(-12, "EUR"))
+}
+
+func TestBudgetPlan_Convert(t *testing.T) {
+	rate := ExchangeRate{From: CurrencyUSD, To: "EUR", Rate: 0.92, AsOf: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	plan, err := NewBudgetPlan(BudgetEstimate{Destinations: []DestinationEstimate{{
+		Destination:  "Peru",
+		Currency:     CurrencyUSD,
+		Nights:       9,
+		Travelers:    1,
+		Items:        []BudgetLineItem{{Category: CostFlights, Amount: 900}, {Category: CostAccommodation, Amount: 900}},
+		Alternatives: "Hostels from $25 a night",
+	}}}, DefaultBudgetRules)
+	require.NoError(t, err)
+	converted := plan.Convert(rate)
+
+	assert.Equal(t, plan.Destinations, converted.Destinations, "the plan's own amounts stay in USD")
+	assert.Equal(t, "1. **Peru**\n"+
+		"   Estimated Budget: ~$1,800 USD (≈ 1,656 EUR) for 1 traveler, 9 nights\n"+
+		"   Budget Category: Medium (~$200 (≈ 184 EUR) per traveler per night)\n"+
+		"   Breakdown: Flights: $900 (≈ 828 EUR), Accommodation: $900 (≈ 828 EUR)\n"+
+		"   Alternatives: Hostels from $25 a night\n\n"+
+		"Amounts in EUR converted at 1 USD = 0.92 EUR, as of 2026-10-01.", converted.Content())
+	assert.NotContains(t, plan.Content(), "EUR")
+
+	unpriced := BudgetPlan{}.Convert(rate)
+	assert.Equal(t, "", unpriced.Content())
+}
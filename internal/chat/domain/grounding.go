@@ -0,0 +1,203 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GroundingIssueKind is what a recommendation mentions without support in the
+// specialists' answers.
+type GroundingIssueKind string
+
+const (
+	IssueDestination GroundingIssueKind = "destination"
+	IssuePrice       GroundingIssueKind = "price"
+)
+
+// GroundingAction is what the grounding verifier does with a recommendation
+// that mentions unsupported destinations or prices.
+type GroundingAction string
+
+const (
+	// GroundingFlag reports the issues and keeps the recommendation.
+	GroundingFlag GroundingAction = "flag"
+	// GroundingRegenerate asks the synthesizer once more, told what to drop,
+	// and flags the new answer if it is still not grounded.
+	GroundingRegenerate GroundingAction = "regenerate"
+)
+
+// GroundingIssue is a destination or price the recommendation made up.
+type GroundingIssue struct {
+	Kind  GroundingIssueKind `json:"kind"`
+	Value string             `json:"value"`
+}
+
+// GroundingReport is the result of checking a recommendation against the
+// artifacts it was synthesized from.
+type GroundingReport struct {
+	Destinations []string         `json:"destinations"` // the destinations the recommendation proposes
+	Prices       []string         `json:"prices"`       // the amounts it quotes, e.g. "$1,800" or "1,656 EUR"
+	Issues       []GroundingIssue `json:"issues"`
+}
+
+// Grounded reports whether every destination and price was supplied.
+func (r GroundingReport) Grounded() bool {
+	return len(r.Issues) == 0
+}
+
+// Correction tells the synthesizer what to drop when it answers again.
+func (r GroundingReport) Correction() string {
+	values := make([]string, len(r.Issues))
+	for i, issue := range r.Issues {
+		values[i] = issue.Value
+	}
+	return fmt.Sprintf("A previous answer mentioned %s, which the input sections do not contain. Only use the destinations and the amounts exactly as the input sections give them.", strings.Join(values, ", "))
+}
+
+// amountPattern matches a number with thousands separators and decimals in
+// any of the supported locales' formats.
+const amountPattern = `\d{1,3}(?:[,.\x{00A0}\x{202F} ]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?`
+
+// decimalMarks are the decimal separators of the supported locales; the
+// others separate thousands.
+var decimalMarks = map[Locale]string{
+	LocaleEnglish:    ".",
+	LocaleSpanish:    ",",
+	LocalePortuguese: ",",
+	LocaleFrench:     ",",
+}
+
+var (
+	// recommendedPlace matches the numbered, bold headings of the synthesizer's
+	// output format, e.g. "1. **Cusco, Peru**".
+	recommendedPlace = regexp.MustCompile(`(?m)^\s*\d+\.\s*\*\*([^*\n]+)\*\*`)
+	// quotedPrice matches the amounts of a recommendation: dollar figures,
+	// e.g. "$1,800 USD", and figures followed by a currency code, e.g.
+	// "1,656 EUR" for a budget converted to the user's currency. Thousands
+	// may be separated by commas, points or spaces, e.g. "1.656 EUR" or
+	// "1 656 EUR".
+	quotedPrice = regexp.MustCompile(`\$\s?(` + amountPattern + `)(?:\s?USD\b)?|\b(` + amountPattern + `)\s?([A-Z]{3})\b`)
+	// placeSeparator splits headings like "Machu Picchu (Cusco, Peru)" into
+	// the names they are made of.
+	placeSeparator = regexp.MustCompile(`\s*(?:[(),/]|\s-\s|\s–\s)\s*`)
+)
+
+// CheckGrounding verifies that the destinations and amounts of a recommendation
+// appear in the artifacts it was built from or, for destinations, among the
+// trip's destinations. An amount must be supplied in the same currency, so a
+// budget converted to the user's currency is checked in both, and amounts are
+// compared by value, read with locale's decimal mark, so "$1,800" grounds
+// "$1.800" in a Spanish answer. Every part of a destination heading must be
+// supplied, so "Cusco, Peru" is grounded by an artifact naming Cusco and a
+// trip to Peru.
+func CheckGrounding(recommendation string, artifacts []Artifact, destinations []string, locale Locale) GroundingReport {
+	var source strings.Builder
+	for _, a := range artifacts {
+		source.WriteString(a.Content())
+		source.WriteString("\n")
+	}
+	for _, d := range destinations {
+		source.WriteString(d)
+		source.WriteString("\n")
+	}
+	text := strings.ToLower(source.String())
+
+	supplied := make(map[string]bool)
+	for _, amount := range quotedAmounts(source.String(), locale) {
+		supplied[amount.key] = true
+	}
+
+	var report GroundingReport
+	for _, match := range recommendedPlace.FindAllStringSubmatch(recommendation, -1) {
+		place := strings.TrimSpace(match[1])
+		report.Destinations = append(report.Destinations, place)
+		for _, name := range placeSeparator.Split(place, -1) {
+			if name != "" && !containsWord(text, strings.ToLower(name)) {
+				report.Issues = append(report.Issues, GroundingIssue{Kind: IssueDestination, Value: place})
+				break
+			}
+		}
+	}
+	for _, amount := range quotedAmounts(recommendation, locale) {
+		report.Prices = append(report.Prices, amount.written)
+		if !supplied[amount.key] {
+			report.Issues = append(report.Issues, GroundingIssue{Kind: IssuePrice, Value: amount.written})
+		}
+	}
+	return report
+}
+
+// quotedAmount is an amount as a text quotes it, e.g. "$1,800", and the key it
+// is compared by, e.g. "1800 USD".
+type quotedAmount struct {
+	written string
+	key     string
+}
+
+func quotedAmounts(text string, locale Locale) []quotedAmount {
+	var amounts []quotedAmount
+	for _, match := range quotedPrice.FindAllStringSubmatch(text, -1) {
+		if match[1] != "" {
+			amounts = append(amounts, quotedAmount{written: "$" + match[1], key: normalizeAmount(match[1], locale) + " " + string(CurrencyUSD)})
+			continue
+		}
+		amounts = append(amounts, quotedAmount{written: match[2] + " " + match[3], key: normalizeAmount(match[2], locale) + " " + match[3]})
+	}
+	return amounts
+}
+
+// normalizeAmount writes an amount as plain digits with a decimal point, so
+// "1,800", "1.800", "1 800" and "1800,00" compare equal. The last separator is
+// the decimal mark if it is locale's and fewer than three digits follow it;
+// every other separator groups thousands, so the English "$1,800" of a budget
+// plan still reads as 1800 in a Spanish conversation.
+func normalizeAmount(amount string, locale Locale) string {
+	whole, fraction := amount, ""
+	if i := strings.LastIndexAny(amount, ",."); i >= 0 && amount[i:i+1] == localized(decimalMarks, locale) && len(amount)-i-1 < 3 {
+		whole, fraction = amount[:i], amount[i+1:]
+	}
+	whole = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, whole)
+	if fraction = strings.TrimRight(fraction, "0"); fraction != "" {
+		return whole + "." + fraction
+	}
+	return whole
+}
+
+// containsWord reports whether word appears in text as a whole word, so "Lima"
+// is not found in "climate". Unlike regexp's \b it treats accented letters as
+// part of a word.
+func containsWord(text, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		if !wordRuneBefore(text[:start]) && !wordRuneAfter(text[end:]) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func wordRuneBefore(s string) bool {
+	r, size := utf8.DecodeLastRuneInString(s)
+	return size > 0 && isWordRune(r)
+}
+
+func wordRuneAfter(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size > 0 && isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckGrounding(t *testing.T) {
+	artifacts := []Artifact{
+		DestinationAdvice{Recommendations: "1. **Cusco** (Peru)\n   Description: gateway to Machu Picchu, mild climate.\n2. **Bogotá** (Colombia)"},
+		BudgetPlan{Destinations: []DestinationBudget{{
+			DestinationEstimate: DestinationEstimate{
+				Destination: "Peru",
+				Nights:      7,
+				Travelers:   2,
+				Items:       []BudgetLineItem{{Category: CostFlights, Amount: 900}, {Category: CostAccommodation, Amount: 600}, {Category: CostFood, Amount: 300}},
+			},
+			Total:    1800,
+			Category: BudgetMedium,
+		}}},
+	}
+	destinations := []string{"Peru", "Colombia"}
+
+	tests := []struct {
+		name           string
+		recommendation string
+		want           []GroundingIssue
+	}{
+		{
+			name:           "grounded",
+			recommendation: "1. **Cusco, Peru**\n   Estimated Budget: ~$1800 USD (flights $900, hotels $600)\n\n2. **Bogotá (Colombia)**\n   Estimated Budget: unknown",
+		},
+		{
+			name:           "invented destination",
+			recommendation: "1. **Arequipa, Peru**\n   Estimated Budget: ~$1,800 USD",
+			want:           []GroundingIssue{{Kind: IssueDestination, Value: "Arequipa, Peru"}},
+		},
+		{
+			name:           "invented price",
+			recommendation: "1. **Cusco**\n   Estimated Budget: ~$2,100 USD, flights $900",
+			want:           []GroundingIssue{{Kind: IssuePrice, Value: "$2,100"}},
+		},
+		{
+			name:           "partial word match",
+			recommendation: "1. **Lima**\n",
+			want:           []GroundingIssue{{Kind: IssueDestination, Value: "Lima"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := CheckGrounding(tt.recommendation, artifacts, destinations, LocaleEnglish)
+			assert.Equal(t, tt.want, report.Issues)
+			assert.Equal(t, len(tt.want) == 0, report.Grounded())
+		})
+	}
+}
+
+func TestCheckGrounding_ConvertedAmounts(t *testing.T) {
+	plan := BudgetPlan{Destinations: []DestinationBudget{{
+		DestinationEstimate: DestinationEstimate{
+			Destination: "Peru",
+			Nights:      7,
+			Travelers:   2,
+			Items:       []BudgetLineItem{{Category: CostFlights, Amount: 900}, {Category: CostAccommodation, Amount: 900}},
+		},
+		Total:    1800,
+		Category: BudgetMedium,
+	}}}
+	artifacts := []Artifact{plan.Convert(ExchangeRate{From: CurrencyUSD, To: "EUR", Rate: 0.92})}
+
+	report := CheckGrounding("1. **Peru**\n   Estimated Budget: ~1,656 EUR ($1,800), flights 828 EUR", artifacts, []string{"Peru"}, LocaleEnglish)
+	assert.True(t, report.Grounded(), "%v", report.Issues)
+
+	report = CheckGrounding("1. **Peru**\n   Estimated Budget: ~1,900 EUR", artifacts, []string{"Peru"}, LocaleEnglish)
+	assert.Equal(t, []GroundingIssue{{Kind: IssuePrice, Value: "1,900 EUR"}}, report.Issues)
+}
+
+func TestCheckGrounding_LocaleFormattedAmounts(t *testing.T) {
+	artifacts := []Artifact{
+		BudgetPlan{Destinations: []DestinationBudget{{
+			DestinationEstimate: DestinationEstimate{
+				Destination: "Peru",
+				Nights:      7,
+				Travelers:   2,
+				Items:       []BudgetLineItem{{Category: CostFlights, Amount: 1250}, {Category: CostAccommodation, Amount: 550}},
+			},
+			Total:    1800,
+			Category: BudgetMedium,
+		}}}.Convert(ExchangeRate{From: CurrencyUSD, To: "EUR", Rate: 0.92}),
+		AgentNote{Agent: "weather_advisor", Text: "Museum passes cost $12,50 in Lima."},
+	}
+
+	tests := []struct {
+		name           string
+		locale         Locale
+		recommendation string
+		want           []GroundingIssue
+	}{
+		{
+			name:           "es grounded",
+			locale:         LocaleSpanish,
+			recommendation: "1. **Peru**\n   Presupuesto estimado: ~$1.800 USD (≈ 1.656 EUR), vuelos $1.250,00, museos $12,5",
+		},
+		{
+			name:           "es invented",
+			locale:         LocaleSpanish,
+			recommendation: "1. **Peru**\n   Presupuesto estimado: ~$2.100 USD",
+			want:           []GroundingIssue{{Kind: IssuePrice, Value: "$2.100"}},
+		},
+		{
+			name:           "fr grounded",
+			locale:         LocaleFrench,
+			recommendation: "1. **Peru**\n   Budget estimé : ~$1 800 USD (≈ 1\u202f656 EUR), vols 1 150 EUR",
+		},
+		{
+			name:           "fr decimals",
+			locale:         LocaleFrench,
+			recommendation: "1. **Peru**\n   Musées : $12,50, hôtel 506,5 EUR",
+			want:           []GroundingIssue{{Kind: IssuePrice, Value: "506,5 EUR"}},
+		},
+		{
+			name:           "en decimals",
+			locale:         LocaleEnglish,
+			recommendation: "1. **Peru**\n   Flights: $1,250.00, museums: $12.50",
+			want:           []GroundingIssue{{Kind: IssuePrice, Value: "$12.50"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := CheckGrounding(tt.recommendation, artifacts, []string{"Peru"}, tt.locale)
+			assert.Equal(t, tt.want, report.Issues)
+		})
+	}
+}
+
+func TestGroundingReport_Correction(t *testing.T) {
+	report := GroundingReport{Issues: []GroundingIssue{{Kind: IssueDestination, Value: "Arequipa"}, {Kind: IssuePrice, Value: "$2,100"}}}
+	assert.Contains(t, report.Correction(), "mentioned Arequipa, $2,100, which the input sections do not contain")
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the untrusted text that reaches the agents.
+const (
+	// MaxUserMessageLength is the longest message, in characters, a user turn
+	// may have.
+	MaxUserMessageLength = 2000
+	// MaxInjectedValueLength caps each trip detail spliced into a system prompt.
+	MaxInjectedValueLength = 80
+	// MaxInjectedValues caps how many values of a trip detail are spliced in.
+	MaxInjectedValues = 10
+)
+
+// BlockReason says why a user message was rejected as a prompt injection.
+type BlockReason string
+
+const (
+	BlockNone                BlockReason = "none"
+	BlockInstructionOverride BlockReason = "instruction_override" // asks to ignore or replace the agents' instructions
+	BlockPromptLeak          BlockReason = "prompt_leak"          // asks for the system prompt
+	BlockRoleHijack          BlockReason = "role_hijack"          // gives the assistant another role or mode
+	BlockDelimiterInjection  BlockReason = "delimiter_injection"  // forges prompt delimiters or template syntax
+	BlockTooLong             BlockReason = "too_long"             // longer than MaxUserMessageLength
+)
+
+// Detector names what rejected a message.
+type Detector string
+
+const (
+	DetectorHeuristic  Detector = "heuristic"
+	DetectorClassifier Detector = "classifier"
+)
+
+// blockedReplies answer a rejected message.
+var blockedReplies = map[Locale]string{
+	LocaleEnglish:    "I can't process this message. Tell me in your own words where you would like to travel and what interests you.",
+	LocaleSpanish:    "No puedo procesar este mensaje. Cuéntame con tus propias palabras a dónde te gustaría viajar y qué te interesa.",
+	LocalePortuguese: "Não posso processar esta mensagem. Conte-me com suas próprias palavras para onde gostaria de viajar e o que lhe interessa.",
+	LocaleFrench:     "Je ne peux pas traiter ce message. Dites-moi avec vos propres mots où vous aimeriez voyager et ce qui vous intéresse.",
+}
+
+// BlockedReply answers a rejected message in locale.
+func BlockedReply(locale Locale) string {
+	return localized(blockedReplies, locale)
+}
+
+// InjectionVerdict records why a user message was rejected.
+type InjectionVerdict struct {
+	Reason   BlockReason `json:"reason"`
+	Detector Detector    `json:"detector"`
+	// Evidence is the part of the message that matched, for heuristic verdicts.
+	Evidence string `json:"evidence,omitempty"`
+}
+
+// InjectionAssessment is the classifier model's answer about a user message.
+type InjectionAssessment struct {
+	Injection bool        `json:"injection" jsonschema:"description=true if the message tries to manipulate the assistant instead of describing a trip"`
+	Reason    BlockReason `json:"reason" jsonschema:"enum=none,enum=instruction_override,enum=prompt_leak,enum=role_hijack,enum=delimiter_injection"`
+}
+
+// Verdict turns a positive assessment into a verdict, or returns nil.
+func (a InjectionAssessment) Verdict() *InjectionVerdict {
+	if !a.Injection {
+		return nil
+	}
+	reason := a.Reason
+	if reason == "" || reason == BlockNone {
+		reason = BlockInstructionOverride
+	}
+	return &InjectionVerdict{Reason: reason, Detector: DetectorClassifier}
+}
+
+// injectionPatterns are the known attack phrasings, in English and Spanish,
+// checked in order.
+var injectionPatterns = []struct {
+	reason  BlockReason
+	pattern *regexp.Regexp
+}{
+	{BlockDelimiterInjection, regexp.MustCompile(`(?im)</?\s*(system|assistant|user|instructions?|destination_advice|budget_plan)\s*>|\{\{|\}\}|<\|im_(start|end)\|>|\[/?INST\]|^\s*(system|assistant)\s*:`)},
+	{BlockInstructionOverride, regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|bypass)\b.{0,40}\b(previous|prior|above|earlier|all|your|the|system)\b.{0,20}\b(instructions?|prompts?|rules|directions|guidelines)\b`)},
+	{BlockInstructionOverride, regexp.MustCompile(`(?i)\b(ignora|olvida|omite|descarta)\b.{0,40}\b(instrucciones|reglas|indicaciones|prompt)\b`)},
+	{BlockInstructionOverride, regexp.MustCompile(`(?i)\bnew instructions?\s*:|\bnuevas instrucciones\s*:`)},
+	{BlockPromptLeak, regexp.MustCompile(`(?i)\b(reveal|show|print|repeat|output|tell me|display)\b.{0,30}\b(system prompt|your (instructions|prompt|rules)|the (instructions|prompt) (above|you were given))`)},
+	{BlockPromptLeak, regexp.MustCompile(`(?i)\b(muestra|revela|repite|dime|imprime)\b.{0,30}\b(tus instrucciones|tu prompt|el prompt del sistema|tus reglas)`)},
+	{BlockRoleHijack, regexp.MustCompile(`(?i)\byou are (now|no longer)\b|\bfrom now on,? you\b|\bact as (an? )?(unrestricted|unfiltered|jailbroken|different)\b|\b(developer|god|dan) mode\b|\bjailbreak\b`)},
+	{BlockRoleHijack, regexp.MustCompile(`(?i)\b(ahora eres|a partir de ahora eres|actúa como (un|una) (ia|asistente) sin)\b|\bmodo desarrollador\b`)},
+}
+
+// DetectInjection checks a user message against known prompt-injection
+// phrasings and the length limit. It returns nil when nothing was found.
+func DetectInjection(message string) *InjectionVerdict {
+	if utf8.RuneCountInString(message) > MaxUserMessageLength {
+		return &InjectionVerdict{Reason: BlockTooLong, Detector: DetectorHeuristic, Evidence: fmt.Sprintf("%d characters", utf8.RuneCountInString(message))}
+	}
+	for _, p := range injectionPatterns {
+		if match := p.pattern.FindString(message); match != "" {
+			return &InjectionVerdict{Reason: p.reason, Detector: DetectorHeuristic, Evidence: strings.TrimSpace(match)}
+		}
+	}
+	return nil
+}
+
+// QuoteValues renders untrusted values for a system prompt: each one is put on
+// a single line, stripped of quotes and template braces, cut to
+// MaxInjectedValueLength and wrapped in double quotes, so the model reads it as
+// data. At most MaxInjectedValues values are kept.
+func QuoteValues(values []string) string {
+	if len(values) > MaxInjectedValues {
+		values = values[:MaxInjectedValues]
+	}
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = sanitizeValue(v); v != "" {
+			quoted = append(quoted, `"`+v+`"`)
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func sanitizeValue(v string) string {
+	v = strings.Map(func(r rune) rune {
+		switch {
+		case r == '"' || r == '`' || r == '{' || r == '}' || r == '<' || r == '>':
+			return -1
+		case unicode.IsControl(r) || unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, v)
+	v = strings.Join(strings.Fields(v), " ")
+	if runes := []rune(v); len(runes) > MaxInjectedValueLength {
+		v = strings.TrimSpace(string(runes[:MaxInjectedValueLength])) + "…"
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IntentField names one of the trip details extracted from the user.
+type IntentField string
+
+const (
+	FieldDestinations IntentField = "Destinations"
+	FieldPreferences  IntentField = "Preferences"
+	FieldInterest     IntentField = "Interest"
+)
+
+// IntentFields lists every field in the order it is merged and reported.
+var IntentFields = []IntentField{FieldDestinations, FieldPreferences, FieldInterest}
+
+// noValues are placeholders a model may answer for a field the user never
+// mentioned; they are not values.
+var noValues = []string{"none", "ninguna", "ninguno"}
+
+// DeltaOp describes how a newly extracted field changes the previous value.
+type DeltaOp string
+
+const (
+	OpKeep    DeltaOp = "keep"
+	OpAdd     DeltaOp = "add"
+	OpReplace DeltaOp = "replace"
+	OpRemove  DeltaOp = "remove"
+)
+
+// FieldDelta is the change requested for a single field in one user turn.
+type FieldDelta struct {
+	Op     DeltaOp
+	Values []string
+}
+
+// IntentDelta holds the per-field changes extracted from the latest user turn.
+// Fields that are not present are kept as they were.
+type IntentDelta map[IntentField]FieldDelta
+
+// Confidence is how sure the extractor is that it understood a field.
+type Confidence string
+
+const (
+	ConfidenceHigh Confidence = "high"
+	ConfidenceLow  Confidence = "low"
+)
+
+// ExtractedField is the extractor's view of one field in the latest user turn.
+type ExtractedField struct {
+	Op         DeltaOp    `json:"op" jsonschema:"enum=keep,enum=add,enum=replace,enum=remove"`
+	Values     []string   `json:"values"`
+	Confidence Confidence `json:"confidence" jsonschema:"enum=high,enum=low"`
+}
+
+// ExtractedIntent is the typed structured output of the information extractor.
+type ExtractedIntent struct {
+	Destinations ExtractedField `json:"destinations"`
+	Preferences  ExtractedField `json:"preferences"`
+	Interest     ExtractedField `json:"interest"`
+	// FollowUp is the question the extractor would ask the user about the
+	// details it is missing or unsure of, if any.
+	FollowUp string `json:"followUp"`
+	// Currency is the ISO 4217 code of the currency the latest message asks
+	// prices in, if any.
+	Currency string `json:"currency"`
+}
+
+// Field returns the extractor's view of one field.
+func (e ExtractedIntent) Field(field IntentField) ExtractedField {
+	switch field {
+	case FieldDestinations:
+		return e.Destinations
+	case FieldPreferences:
+		return e.Preferences
+	case FieldInterest:
+		return e.Interest
+	}
+	return ExtractedField{}
+}
+
+// Delta converts the extractor output into the change to merge into the known intent.
+func (e ExtractedIntent) Delta() IntentDelta {
+	return IntentDelta{
+		FieldDestinations: FieldDelta{Op: e.Destinations.Op, Values: e.Destinations.Values},
+		FieldPreferences:  FieldDelta{Op: e.Preferences.Op, Values: e.Preferences.Values},
+		FieldInterest:     FieldDelta{Op: e.Interest.Op, Values: e.Interest.Values},
+	}
+}
+
+// TravelIntent is the accumulated trip details for a conversation.
+type TravelIntent struct {
+	Destinations []string
+	Preferences  []string
+	Interest     []string
+}
+
+// Values returns the current values of a field.
+func (t TravelIntent) Values(field IntentField) []string {
+	switch field {
+	case FieldDestinations:
+		return t.Destinations
+	case FieldPreferences:
+		return t.Preferences
+	case FieldInterest:
+		return t.Interest
+	}
+	return nil
+}
+
+// Text renders a field as it is injected into agent prompts, quoted with
+// QuoteValues. It is empty for a field the user never mentioned, so templates
+// handle a missing field themselves and required keys fail to render.
+func (t TravelIntent) Text(field IntentField) string {
+	return QuoteValues(t.Values(field))
+}
+
+func (t *TravelIntent) set(field IntentField, values []string) {
+	switch field {
+	case FieldDestinations:
+		t.Destinations = values
+	case FieldPreferences:
+		t.Preferences = values
+	case FieldInterest:
+		t.Interest = values
+	}
+}
+
+// Apply merges a delta into the intent and returns the merged intent along with
+// the fields whose values actually changed. The merge is deterministic: existing
+// values keep their order, added values are appended in the order given, and
+// values are compared case-insensitively.
+func (t TravelIntent) Apply(delta IntentDelta) (TravelIntent, []IntentField) {
+	merged := t
+	var changed []IntentField
+
+	for _, field := range IntentFields {
+		d, ok := delta[field]
+		if !ok {
+			continue
+		}
+
+		current := t.Values(field)
+		values := NormalizeValues(d.Values)
+
+		var next []string
+		switch d.Op {
+		case OpAdd:
+			next = NormalizeValues(append(append([]string{}, current...), values...))
+		case OpReplace:
+			if len(values) == 0 {
+				continue
+			}
+			next = values
+		case OpRemove:
+			for _, v := range current {
+				if !containsFold(values, v) {
+					next = append(next, v)
+				}
+			}
+		default:
+			continue
+		}
+
+		if !sameValues(current, next) {
+			merged.set(field, next)
+			changed = append(changed, field)
+		}
+	}
+
+	return merged, changed
+}
+
+// NormalizeValues trims values, drops empty values and placeholders and removes
+// case-insensitive duplicates while keeping the first occurrence.
+func NormalizeValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || containsFold(noValues, v) || containsFold(out, v) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func containsFold(values []string, v string) bool {
+	for _, existing := range values {
+		if strings.EqualFold(existing, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// AgentResult is the last answer an agent produced together with the key of the
+// inputs it was produced from, so unchanged inputs can reuse the answer.
+type AgentResult struct {
+	InputKey string
+	Output   string
+}
+
+// TripState is what the orchestrator remembers about a conversation between turns.
+type TripState struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	Intent         TravelIntent
+	AgentResults   map[Agent]AgentResult
+	// PendingClarification is the question the last turn stopped on, until a
+	// later turn provides the missing details.
+	PendingClarification *Clarification
+	// BlockedMessages are the user messages rejected as prompt injections, which
+	// the agents never see.
+	BlockedMessages []uuid.UUID
+	// Locale is the language the conversation is answered in, kept for the
+	// messages whose language cannot be told, such as a bare place name.
+	Locale Locale
+	// Currency is the currency the user wants prices in, empty for USD.
+	Currency  Currency
+	UpdatedAt time.Time
+}
+
+// NewTripState creates an empty state for a conversation.
+func NewTripState(conversationID, userID uuid.UUID) *TripState {
+	return &TripState{
+		ConversationID: conversationID,
+		UserID:         userID,
+		AgentResults:   map[Agent]AgentResult{},
+		UpdatedAt:      time.Now().UTC(),
+	}
+}
+
+// CachedResult returns the stored answer for an agent if it was produced from the same inputs.
+func (s *TripState) CachedResult(agent Agent, inputKey string) (string, bool) {
+	result, ok := s.AgentResults[agent]
+	if !ok || result.InputKey != inputKey || result.Output == "" {
+		return "", false
+	}
+	return result.Output, true
+}
+
+// LastResult returns the agent's most recent answer, whatever inputs it was
+// produced from.
+func (s *TripState) LastResult(agent Agent) (string, bool) {
+	result, ok := s.AgentResults[agent]
+	if !ok || result.Output == "" {
+		return "", false
+	}
+	return result.Output, true
+}
+
+// RecordResult stores the answer an agent produced for the given inputs.
+func (s *TripState) RecordResult(agent Agent, inputKey, output string) {
+	if s.AgentResults == nil {
+		s.AgentResults = map[Agent]AgentResult{}
+	}
+	s.AgentResults[agent] = AgentResult{InputKey: inputKey, Output: output}
+	s.UpdatedAt = time.Now().UTC()
+}
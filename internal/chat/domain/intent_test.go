@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTravelIntent_Apply(t *testing.T) {
+	base := TravelIntent{
+		Destinations: []string{"Panama", "Costa Rica"},
+		Preferences:  []string{"mid-range hotels"},
+		Interest:     []string{"beaches"},
+	}
+
+	tests := []struct {
+		name    string
+		delta   IntentDelta
+		want    TravelIntent
+		changed []IntentField
+	}{
+		{
+			name:  "keep leaves everything untouched",
+			delta: IntentDelta{FieldDestinations: {Op: OpKeep, Values: []string{"Peru"}}},
+			want:  base,
+		},
+		{
+			name:    "replace swaps the destinations",
+			delta:   IntentDelta{FieldDestinations: {Op: OpReplace, Values: []string{"Peru"}}},
+			want:    TravelIntent{Destinations: []string{"Peru"}, Preferences: base.Preferences, Interest: base.Interest},
+			changed: []IntentField{FieldDestinations},
+		},
+		{
+			name:    "add appends without duplicates",
+			delta:   IntentDelta{FieldDestinations: {Op: OpAdd, Values: []string{"panama", "Guatemala"}}},
+			want:    TravelIntent{Destinations: []string{"Panama", "Costa Rica", "Guatemala"}, Preferences: base.Preferences, Interest: base.Interest},
+			changed: []IntentField{FieldDestinations},
+		},
+		{
+			name:    "remove is case insensitive",
+			delta:   IntentDelta{FieldDestinations: {Op: OpRemove, Values: []string{"costa rica"}}},
+			want:    TravelIntent{Destinations: []string{"Panama"}, Preferences: base.Preferences, Interest: base.Interest},
+			changed: []IntentField{FieldDestinations},
+		},
+		{
+			name:  "replace with only placeholders is ignored",
+			delta: IntentDelta{FieldPreferences: {Op: OpReplace, Values: []string{"ninguna"}}},
+			want:  base,
+		},
+		{
+			name: "multiple fields report in field order",
+			delta: IntentDelta{
+				FieldInterest:    {Op: OpReplace, Values: []string{"hiking"}},
+				FieldPreferences: {Op: OpReplace, Values: []string{"cheap hostels"}},
+			},
+			want:    TravelIntent{Destinations: base.Destinations, Preferences: []string{"cheap hostels"}, Interest: []string{"hiking"}},
+			changed: []IntentField{FieldPreferences, FieldInterest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := base.Apply(tt.delta)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.changed, changed)
+		})
+	}
+}
+
+func TestTravelIntent_Text(t *testing.T) {
+	intent := TravelIntent{Destinations: []string{"Panama", "Peru"}}
+	assert.Equal(t, `"Panama", "Peru"`, intent.Text(FieldDestinations))
+	assert.Equal(t, "", intent.Text(FieldInterest))
+}
@@ -1,13 +1,20 @@
 // This implemenation can be extended to use tools, or structured outputs or simple completions
 package domain
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 type LLMModel string
 
 // LLMProvider defines the expected behavior from a Large Language Model provider.
 type LLMClient interface {
-	StructuredOutput(ctx context.Context, messages []Message, model string, schema any) (map[string]string, error)
+	// StructuredOutput returns the model's JSON answer conforming to schema.
+	StructuredOutput(ctx context.Context, messages []Message, model string, schema any) (json.RawMessage, error)
 	Chat(ctx context.Context, messages []Message, model string) (Message, error)
 	StreamChat(ctx context.Context, messages []Message, streamFn func(string) error, model string) error
+	// ChatWithTools lets the model call the toolbox's tools until it settles on
+	// a final answer.
+	ChatWithTools(ctx context.Context, messages []Message, model string, toolbox Toolbox) (Message, error)
 }
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Locale is the language a conversation is answered in, as a lowercase ISO
+// 639-1 code.
+type Locale string
+
+const (
+	LocaleEnglish    Locale = "en"
+	LocaleSpanish    Locale = "es"
+	LocalePortuguese Locale = "pt"
+	LocaleFrench     Locale = "fr"
+)
+
+// DefaultLocale is the language of the agents' default templates, used when
+// the user's language is unknown.
+const DefaultLocale = LocaleEnglish
+
+// languageNames are the locales the pipeline can answer in.
+var languageNames = map[Locale]string{
+	LocaleEnglish:    "English",
+	LocaleSpanish:    "Spanish",
+	LocalePortuguese: "Portuguese",
+	LocaleFrench:     "French",
+}
+
+// Language names the locale's language in English, as agent templates
+// mention it, or returns "" for an unsupported locale.
+func (l Locale) Language() string {
+	return languageNames[l]
+}
+
+// localized returns the entry of texts for locale, or the DefaultLocale one.
+func localized[T any](texts map[Locale]T, locale Locale) T {
+	if text, ok := texts[locale]; ok {
+		return text
+	}
+	return texts[DefaultLocale]
+}
+
+// ParseLocale reads a language tag such as "es" or "es-MX". It reports false
+// for languages the pipeline cannot answer in.
+func ParseLocale(tag string) (Locale, bool) {
+	base, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	base, _, _ = strings.Cut(base, "_")
+	locale := Locale(strings.ToLower(base))
+	if _, ok := languageNames[locale]; !ok {
+		return "", false
+	}
+	return locale, true
+}
+
+// ParseAcceptLanguage returns the supported locale the header of an HTTP
+// request prefers most, e.g. "es" for "fr-CH;q=0.4, es-MX, en;q=0.8". It
+// reports false when the header names no supported language.
+func ParseAcceptLanguage(header string) (Locale, bool) {
+	var best Locale
+	bestWeight := 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		locale, ok := ParseLocale(tag)
+		if ok && weight > bestWeight {
+			best, bestWeight = locale, weight
+		}
+	}
+	return best, best != ""
+}
+
+// languageWords are frequent words of each supported language that are rare
+// in the others.
+var languageWords = map[Locale][]string{
+	LocaleEnglish: {
+		"the", "and", "to", "i", "want", "is", "of", "for", "my", "with", "we", "you", "it", "what", "where",
+		"how", "would", "like", "go", "trip", "travel", "can", "please", "some", "somewhere", "maybe", "or",
+		"make", "actually", "love", "cheap", "cheaper", "visit", "about", "in", "our", "family", "hotel", "hotels",
+	},
+	LocaleSpanish: {
+		"el", "la", "los", "las", "y", "quiero", "para", "por", "con", "mi", "mis", "viaje", "viajar", "es",
+		"una", "un", "del", "al", "quisiera", "gustaría", "dónde", "cómo", "qué", "algo", "barato", "más",
+		"hoteles", "vacaciones", "quizás", "mejor", "también", "nosotros", "playa", "pero", "o", "familia",
+	},
+	LocalePortuguese: {
+		"o", "os", "as", "e", "eu", "quero", "para", "com", "meu", "minha", "viagem", "viajar", "é", "uma", "um",
+		"do", "da", "no", "na", "gostaria", "onde", "como", "barato", "mais", "férias", "talvez", "também", "praia",
+		"mas", "ou", "não", "família",
+	},
+	LocaleFrench: {
+		"le", "la", "les", "et", "je", "veux", "pour", "avec", "mon", "ma", "mes", "voyage", "voyager", "est",
+		"une", "un", "du", "au", "aimerais", "où", "comment", "quoi", "pas", "cher", "plus", "vacances",
+		"peut-être", "aussi", "plage", "mais", "ou", "famille", "nous",
+	},
+}
+
+// languageLetters are letters only one supported language uses.
+var languageLetters = map[rune]Locale{
+	'ñ': LocaleSpanish, '¿': LocaleSpanish, '¡': LocaleSpanish,
+	'ã': LocalePortuguese, 'õ': LocalePortuguese,
+	'ç': LocaleFrench, 'è': LocaleFrench, 'ê': LocaleFrench, 'à': LocaleFrench,
+}
+
+// DetectLocale guesses the language of a user message from its words. It
+// reports false when the message is too short or too mixed to tell, e.g. a
+// bare place name, so the caller can keep the conversation's language.
+func DetectLocale(text string) (Locale, bool) {
+	scores := make(map[Locale]int, len(languageNames))
+	for _, r := range strings.ToLower(text) {
+		if locale, ok := languageLetters[r]; ok {
+			scores[locale] += 2
+		}
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+	for _, word := range words {
+		for locale, common := range languageWords {
+			if slices.Contains(common, word) {
+				scores[locale]++
+			}
+		}
+	}
+
+	var best Locale
+	bestScore, runnerUp := 0, 0
+	for _, locale := range []Locale{LocaleEnglish, LocaleSpanish, LocalePortuguese, LocaleFrench} {
+		switch score := scores[locale]; {
+		case score > bestScore:
+			best, bestScore, runnerUp = locale, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore == 0 || bestScore == runnerUp {
+		return "", false
+	}
+	return best, true
+}
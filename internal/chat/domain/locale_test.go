@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLocale(t *testing.T) {
+	tests := []struct {
+		message string
+		want    Locale
+		ok      bool
+	}{
+		{message: "I want to go hiking", want: LocaleEnglish, ok: true},
+		{message: "Actually make it cheaper", want: LocaleEnglish, ok: true},
+		{message: "Quiero ir de vacaciones a la playa con mi familia", want: LocaleSpanish, ok: true},
+		{message: "¿Perú o Chile?", want: LocaleSpanish, ok: true},
+		{message: "Eu gostaria de viajar para a praia", want: LocalePortuguese, ok: true},
+		{message: "Je veux voyager avec ma famille", want: LocaleFrench, ok: true},
+		{message: "Peru"},
+		{message: "Lima, Cusco!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			got, ok := DetectLocale(tt.message)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Locale
+		ok     bool
+	}{
+		{header: "es-MX", want: LocaleSpanish, ok: true},
+		{header: "fr-CH;q=0.4, es-MX, en;q=0.8", want: LocaleSpanish, ok: true},
+		{header: "de-DE, en;q=0.5", want: LocaleEnglish, ok: true},
+		{header: "pt_BR;q=0.9, en;q=bad", want: LocalePortuguese, ok: true},
+		{header: "de, it;q=0.8"},
+		{header: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := ParseAcceptLanguage(tt.header)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
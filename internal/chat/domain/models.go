@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Capability is something a model can do beyond plain chat.
+type Capability string
+
+const (
+	CapabilityChat             Capability = "chat"
+	CapabilityStreaming        Capability = "streaming"
+	CapabilityStructuredOutput Capability = "structured_output"
+	CapabilityTools            Capability = "tools"
+)
+
+// ModelSpec describes a model known to the service under a stable alias.
+type ModelSpec struct {
+	Alias               LLMModel
+	ProviderModel       string // ID sent to the provider, e.g. "gpt-4o-2024-08-06"
+	ContextWindow       int    // in tokens
+	InputPricePerToken  float64
+	OutputPricePerToken float64
+	Capabilities        []Capability
+}
+
+// Supports reports whether the model has the given capability.
+func (m ModelSpec) Supports(c Capability) bool {
+	for _, have := range m.Capabilities {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Cost returns the price of a call with the given token counts.
+func (m ModelSpec) Cost(inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)*m.InputPricePerToken + float64(outputTokens)*m.OutputPricePerToken
+}
+
+// Errors returned by the model registry.
+var (
+	ErrUnknownModel = errors.New("unknown model")
+)
+
+// ModelRegistry maps model aliases to provider models and their capabilities.
+type ModelRegistry struct {
+	models map[LLMModel]ModelSpec
+}
+
+// NewModelRegistry builds a registry, rejecting empty or duplicated aliases.
+func NewModelRegistry(specs ...ModelSpec) (*ModelRegistry, error) {
+	models := make(map[LLMModel]ModelSpec, len(specs))
+	for _, spec := range specs {
+		if spec.Alias == "" {
+			return nil, errors.New("model registry: model without alias")
+		}
+		if spec.ProviderModel == "" {
+			return nil, fmt.Errorf("model registry: %s has no provider model", spec.Alias)
+		}
+		if _, dup := models[spec.Alias]; dup {
+			return nil, fmt.Errorf("model registry: duplicate alias %s", spec.Alias)
+		}
+		models[spec.Alias] = spec
+	}
+	return &ModelRegistry{models: models}, nil
+}
+
+// Resolve returns the spec registered under alias.
+func (r *ModelRegistry) Resolve(alias LLMModel) (ModelSpec, error) {
+	spec, ok := r.models[alias]
+	if !ok {
+		return ModelSpec{}, fmt.Errorf("%w: %s", ErrUnknownModel, alias)
+	}
+	return spec, nil
+}
+
+// ValidateAssignments checks that every agent with requirements has a model
+// assigned and that the model supports what the agent needs. All problems are
+// reported at once.
+func (r *ModelRegistry) ValidateAssignments(assignments map[Agent]LLMModel, requirements map[Agent][]Capability) error {
+	agents := make([]string, 0, len(requirements))
+	for agent := range requirements {
+		agents = append(agents, string(agent))
+	}
+	sort.Strings(agents)
+
+	var problems []string
+	for _, name := range agents {
+		agent := Agent(name)
+		alias, ok := assignments[agent]
+		if !ok || alias == "" {
+			problems = append(problems, fmt.Sprintf("%s has no model assigned", agent))
+			continue
+		}
+		spec, err := r.Resolve(alias)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", agent, err))
+			continue
+		}
+		for _, need := range requirements[agent] {
+			if !spec.Supports(need) {
+				problems = append(problems, fmt.Sprintf("%s: model %s does not support %s", agent, alias, need))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid agent models: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
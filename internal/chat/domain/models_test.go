@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelRegistry_ValidateAssignments(t *testing.T) {
+	registry, err := NewModelRegistry(
+		ModelSpec{Alias: "smart", ProviderModel: "gpt-4o", Capabilities: []Capability{CapabilityChat, CapabilityStreaming, CapabilityStructuredOutput}},
+		ModelSpec{Alias: "cheap", ProviderModel: "gpt-3.5-turbo", Capabilities: []Capability{CapabilityChat, CapabilityStreaming}},
+	)
+	require.NoError(t, err)
+
+	spec, err := registry.Resolve("cheap")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-3.5-turbo", spec.ProviderModel)
+
+	_, err = registry.Resolve("missing")
+	assert.ErrorIs(t, err, ErrUnknownModel)
+
+	requirements := map[Agent][]Capability{
+		InformationExtractor: {CapabilityStructuredOutput},
+		DestinationExpert:    {CapabilityChat, CapabilityStreaming},
+		BudgetPlanner:        {CapabilityChat, CapabilityStreaming},
+		TripSynthesizer:      {CapabilityChat, CapabilityStreaming},
+	}
+	valid := map[Agent]LLMModel{
+		InformationExtractor: "smart",
+		DestinationExpert:    "cheap",
+		BudgetPlanner:        "cheap",
+		TripSynthesizer:      "smart",
+	}
+	assert.NoError(t, registry.ValidateAssignments(valid, requirements))
+
+	invalid := map[Agent]LLMModel{
+		InformationExtractor: "cheap",
+		DestinationExpert:    "missing",
+		TripSynthesizer:      "smart",
+	}
+	err = registry.ValidateAssignments(invalid, requirements)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "budget_planner has no model assigned")
+	assert.Contains(t, err.Error(), "destination_expert: unknown model: missing")
+	assert.Contains(t, err.Error(), "information_extractor: model cheap does not support structured_output")
+}
+
+func TestNewModelRegistry_RejectsDuplicates(t *testing.T) {
+	_, err := NewModelRegistry(
+		ModelSpec{Alias: "a", ProviderModel: "x"},
+		ModelSpec{Alias: "a", ProviderModel: "y"},
+	)
+	assert.ErrorContains(t, err, "duplicate alias a")
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FailurePolicy decides what a run does when an agent fails or misses its deadline.
+type FailurePolicy string
+
+const (
+	// PolicyFail aborts the whole run.
+	PolicyFail FailurePolicy = "fail"
+	// PolicyContinue goes on without the agent's answer.
+	PolicyContinue FailurePolicy = "continue"
+	// PolicyRetry runs the agent once more and goes on without it if the retry
+	// fails too, unless the pipeline requires the agent: then the run fails.
+	PolicyRetry FailurePolicy = "retry"
+	// PolicyCached substitutes the agent's answer from an earlier turn, even if
+	// its inputs changed since, and goes on without it when there is none.
+	PolicyCached FailurePolicy = "cached"
+)
+
+// Valid reports whether p is one of the known policies.
+func (p FailurePolicy) Valid() bool {
+	switch p {
+	case PolicyFail, PolicyContinue, PolicyRetry, PolicyCached:
+		return true
+	}
+	return false
+}
+
+// Degrades reports whether p goes on without the agent's fresh answer as soon
+// as the agent fails.
+func (p FailurePolicy) Degrades() bool {
+	return p == PolicyContinue || p == PolicyCached
+}
+
+// AgentPolicy bounds an agent's run and says what happens when it fails.
+type AgentPolicy struct {
+	Timeout   time.Duration // per attempt; zero means only the run's deadline applies
+	OnFailure FailurePolicy
+}
+
+// Validate rejects negative timeouts and unknown failure policies.
+func (p AgentPolicy) Validate() error {
+	if p.Timeout < 0 {
+		return fmt.Errorf("negative timeout %s", p.Timeout)
+	}
+	if !p.OnFailure.Valid() {
+		return fmt.Errorf("unknown failure policy %q", p.OnFailure)
+	}
+	return nil
+}
+
+// DegradationOutcome is how a run made up for a failed agent.
+type DegradationOutcome string
+
+const (
+	OutcomeOmitted DegradationOutcome = "omitted" // the answer is missing
+	OutcomeCached  DegradationOutcome = "cached"  // an answer from an earlier turn was used
+)
+
+// Degradation records that a run went on without an agent's fresh answer.
+type Degradation struct {
+	Agent   Agent              `json:"agent"`
+	Policy  FailurePolicy      `json:"policy"`
+	Outcome DegradationOutcome `json:"outcome"`
+	Reason  string             `json:"reason"`
+}
+
+// Caveat tells the synthesizer how the degradation affects its input.
+func (d Degradation) Caveat() string {
+	name := strings.ReplaceAll(string(d.Agent), "_", " ")
+	if d.Outcome == OutcomeCached {
+		return fmt.Sprintf("The %s answer is from an earlier request and may not reflect the latest trip details; say so when you use it.", name)
+	}
+	return fmt.Sprintf("The %s did not answer; do not invent what it would have provided and tell the user that part is missing.", name)
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ToolHandler runs a tool with the JSON arguments chosen by the model and
+// returns the text handed back to the model.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Tool is Go code a model may call while answering.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  any    // JSON schema of the arguments object, e.g. from llm.GenerateSchema
+	Status      string // shown to the user while the tool runs, e.g. "Checking prices…"
+	Handler     ToolHandler
+}
+
+// ToolCall is one tool invocation requested by a model.
+type ToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// ToolObserver is told about every tool call just before it runs.
+type ToolObserver func(tool Tool, call ToolCall)
+
+// Toolbox is what a single model call may use: the tools on offer, a hook
+// reporting their invocations and, optionally, a consumer of the answer as it
+// is written.
+type Toolbox struct {
+	Tools   []Tool
+	Observe ToolObserver
+	// Stream, when set, receives the final answer chunk by chunk while the model
+	// writes it. An error from Stream aborts the call.
+	Stream func(chunk string) error
+}
+
+// Find returns the tool registered under name.
+func (b Toolbox) Find(name string) (Tool, bool) {
+	for _, tool := range b.Tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+// ToolRegistry holds the tools each agent is allowed to call.
+type ToolRegistry struct {
+	tools map[Agent][]Tool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[Agent][]Tool)}
+}
+
+// Register offers tools to agent. Tool names must be unique per agent.
+func (r *ToolRegistry) Register(agent Agent, tools ...Tool) error {
+	for _, tool := range tools {
+		if tool.Name == "" {
+			return errors.New("tool registry: tool without name")
+		}
+		if tool.Handler == nil {
+			return fmt.Errorf("tool registry: %s has no handler", tool.Name)
+		}
+		if _, dup := (Toolbox{Tools: r.tools[agent]}).Find(tool.Name); dup {
+			return fmt.Errorf("tool registry: duplicate tool %s for %s", tool.Name, agent)
+		}
+		r.tools[agent] = append(r.tools[agent], tool)
+	}
+	return nil
+}
+
+// For returns the tools registered for agent. A nil registry has none.
+func (r *ToolRegistry) For(agent Agent) []Tool {
+	if r == nil {
+		return nil
+	}
+	return r.tools[agent]
+}
+
+// Requirements adds the tools capability to base for every agent with tools, so
+// model assignments can be validated against both.
+func (r *ToolRegistry) Requirements(base map[Agent][]Capability) map[Agent][]Capability {
+	out := make(map[Agent][]Capability, len(base))
+	for agent, needs := range base {
+		out[agent] = append([]Capability(nil), needs...)
+	}
+	if r == nil {
+		return out
+	}
+	for agent, tools := range r.tools {
+		if len(tools) > 0 {
+			out[agent] = append(out[agent], CapabilityTools)
+		}
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolRegistry(t *testing.T) {
+	handler := func(ctx context.Context, _ json.RawMessage) (string, error) { return "ok", nil }
+	registry := NewToolRegistry()
+
+	require.NoError(t, registry.Register(BudgetPlanner, Tool{Name: "check_prices", Handler: handler}))
+	assert.Error(t, registry.Register(BudgetPlanner, Tool{Name: "check_prices", Handler: handler}))
+	assert.Error(t, registry.Register(BudgetPlanner, Tool{Name: "no_handler"}))
+	assert.Len(t, registry.For(BudgetPlanner), 1)
+	assert.Empty(t, registry.For(DestinationExpert))
+
+	base := map[Agent][]Capability{
+		DestinationExpert: {CapabilityChat, CapabilityStreaming},
+		BudgetPlanner:     {CapabilityChat, CapabilityStreaming},
+	}
+	requirements := registry.Requirements(base)
+	assert.Equal(t, []Capability{CapabilityChat, CapabilityStreaming, CapabilityTools}, requirements[BudgetPlanner])
+	assert.Equal(t, []Capability{CapabilityChat, CapabilityStreaming}, requirements[DestinationExpert])
+	assert.Equal(t, []Capability{CapabilityChat, CapabilityStreaming}, base[BudgetPlanner], "base requirements are not modified")
+}
@@ -3,9 +3,14 @@ package server
 import (
 	chathttpadapter "acai_travel/internal/chat/adapters/chat_http_adapter"
 	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/adapters/repository"
 	"acai_travel/internal/chat/application"
+	"acai_travel/internal/chat/config"
+	"acai_travel/internal/chat/domain"
 	"bufio"
+	"context"
 	"fmt"
+	"log"
 	"os"
 	"sync"
 	"time"
@@ -14,28 +19,80 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
+// corsConfig lets browser clients on other origins resume a run with
+// Last-Event-ID and read the run's X-Run-ID.
+var corsConfig = cors.Config{
+	AllowOrigins:     "*",
+	AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
+	AllowHeaders:     "Accept,Authorization,Content-Type,Last-Event-ID",
+	ExposeHeaders:    "X-Run-ID",
+	AllowCredentials: false,
+	MaxAge:           300,
+}
+
 func (s *FiberServer) RegisterFiberRoutes() {
-	s.App.Use(cors.New(cors.Config{
-		AllowOrigins:     "*",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Accept,Authorization,Content-Type",
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	s.App.Use(cors.New(corsConfig))
 
 	s.App.Get("/", s.HelloWorldHandler)
-	openaiApiKey := os.Getenv("OPENAI_API_KEY")
-	openaiClient := llm.NewOpenAIClient(openaiApiKey)
+	openaiConfig, err := config.LoadOpenAIConfig()
+	if err != nil {
+		log.Fatalf("Invalid OpenAI configuration: %v", err)
+	}
+	agents := application.TravelAgents()
+	if dir := os.Getenv("PROMPTS_DIR"); dir != "" {
+		prompts := config.NewPromptWatcher(dir, agents)
+		if err := prompts.Reload(); err != nil {
+			log.Fatalf("Invalid prompt templates: %v", err)
+		}
+		go prompts.Watch(context.Background(), config.DefaultPromptReloadInterval)
+	}
+	modelConfig, err := config.LoadModelConfig(os.Getenv("MODELS_CONFIG"), agents)
+	if err != nil {
+		log.Fatalf("Invalid model configuration: %v", err)
+	}
+	ratesFile := os.Getenv("EXCHANGE_RATES_FILE")
+	rates, err := config.LoadExchangeRates(ratesFile)
+	if err != nil {
+		log.Fatalf("Invalid exchange rates: %v", err)
+	}
+	if ratesFile != "" {
+		go rates.Watch(context.Background(), config.DefaultExchangeRateRefreshInterval)
+	}
+	tools, err := application.NewTravelTools(time.Now, rates)
+	if err != nil {
+		log.Fatalf("Invalid agent tools: %v", err)
+	}
+	if err := modelConfig.Registry.ValidateAssignments(modelConfig.AgentModels, tools.Requirements(agents.Requirements())); err != nil {
+		log.Fatalf("Invalid model configuration: %v", err)
+	}
+	clientOptions := append(openaiConfig.ClientOptions(), llm.WithModelRegistry(modelConfig.Registry), llm.WithMaxRetries(0))
+	openaiClient := llm.Chain(
+		llm.NewOpenAIClient(openaiConfig.APIKey, clientOptions...),
+		llm.WithRetry(llm.DefaultRetryPolicy()),
+		llm.WithCircuitBreaker(llm.DefaultBreakerConfig()),
+	)
+
+	agentRunner := application.NewAgentRunner(openaiClient)
+	structuredRunner := application.NewStructuredRunner(openaiClient)
 
-	infoExtractor := application.NewInformationExtractor(openaiClient)
-	destExper := application.NewDestinationExpert(openaiClient)
-	budgetPlanner := application.NewBudgetPlanner(openaiClient)
-	tripSynth := application.NewTripSynthesizer(openaiClient)
+	chat_service := application.NewChatService(agentRunner, structuredRunner)
 
-	chat_service := application.NewChatService(destExper, budgetPlanner, tripSynth, infoExtractor)
+	pipeline, err := application.TravelPipeline().WithPolicies(modelConfig.AgentPolicies)
+	if err != nil {
+		log.Fatalf("Invalid agent policies: %v", err)
+	}
+	budgetRules, err := config.LoadBudgetRules(os.Getenv("BUDGET_RULES_FILE"))
+	if err != nil {
+		log.Fatalf("Invalid budget rules: %v", err)
+	}
+	if pipeline, err = pipeline.WithStep(domain.BudgetPlanner, application.PlanBudget(budgetRules)); err != nil {
+		log.Fatalf("Invalid budget planner: %v", err)
+	}
 
-	orchestrator := application.NewMultiAgentOrchestrator(chat_service)
-	handler := chathttpadapter.NewTravelHandler(orchestrator)
+	store := newConversationStore()
+	orchestrator := application.NewMultiAgentOrchestrator(chat_service, store, store, store, modelConfig.AgentModels, agents, tools, pipeline, rates)
+	history := application.NewConversationHistory(store, store)
+	handler := chathttpadapter.NewTravelHandler(orchestrator, history)
 	handler.RegisterRoutes(s.App)
 
 	s.App.Get("/events", func(c *fiber.Ctx) error {
@@ -89,6 +146,28 @@ func (s *FiberServer) RegisterFiberRoutes() {
 
 }
 
+// conversationStore is implemented by every adapter in the repository package.
+type conversationStore interface {
+	application.ChatRepository
+	application.TripStateRepository
+	application.AgentSessionRepository
+}
+
+// newConversationStore persists conversations on disk when CHAT_STORE_DIR is set and
+// falls back to an in-memory store otherwise.
+func newConversationStore() conversationStore {
+	dir := os.Getenv("CHAT_STORE_DIR")
+	if dir == "" {
+		return repository.NewMemoryStore()
+	}
+
+	store, err := repository.NewFileStore(dir)
+	if err != nil {
+		log.Fatalf("Invalid CHAT_STORE_DIR: %v", err)
+	}
+	return store
+}
+
 func func1() string {
 	time.Sleep(10 * time.Second)
 	return "resultado de func1"
@@ -1,10 +1,19 @@
 package server
 
 import (
-	"github.com/gofiber/fiber/v2"
+	chathttpadapter "acai_travel/internal/chat/adapters/chat_http_adapter"
+	"acai_travel/internal/chat/adapters/llm"
+	"acai_travel/internal/chat/adapters/repository"
+	"acai_travel/internal/chat/application"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/google/uuid"
 )
 
 func TestHandler(t *testing.T) {
@@ -37,3 +46,57 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestCORS(t *testing.T) {
+	client := llm.NewScriptedClient(llm.ScriptRule{Name: "any", Err: errors.New("offline")})
+	store := repository.NewMemoryStore()
+	service := application.NewChatService(application.NewAgentRunner(client), application.NewStructuredRunner(client))
+	orchestrator := application.NewMultiAgentOrchestrator(service, store, store, store, nil, nil, nil, nil, nil)
+
+	app := fiber.New()
+	app.Use(cors.New(corsConfig))
+	chathttpadapter.NewTravelHandler(orchestrator, application.NewConversationHistory(store, store)).RegisterRoutes(app)
+
+	preflight, err := http.NewRequest("OPTIONS", "/travel/recommendation", nil)
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "Last-Event-ID")
+	resp, err := app.Test(preflight)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	if allowed := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "Last-Event-ID") {
+		t.Errorf("expected Last-Event-ID to be allowed; got %q", allowed)
+	}
+
+	body := `{"conversationId":"` + uuid.NewString() + `","userId":"` + uuid.NewString() + `",` +
+		`"message":{"role":"user","content":"Beaches in Panama"}}`
+	req, err := http.NewRequest("POST", "/travel/recommendation", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	if resp.Header.Get("X-Run-ID") == "" {
+		t.Errorf("expected the run's X-Run-ID header")
+	}
+	// A browser only lets the page read X-Run-ID if the response allows its
+	// origin and exposes the header.
+	if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("expected any origin to be allowed; got %q", origin)
+	}
+	if exposed := resp.Header.Get("Access-Control-Expose-Headers"); exposed != "X-Run-ID" {
+		t.Errorf("expected X-Run-ID to be exposed; got %q", exposed)
+	}
+}
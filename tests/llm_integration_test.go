@@ -4,7 +4,6 @@ import (
 	"acai_travel/internal/chat/adapters/llm"
 	"acai_travel/internal/chat/domain"
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
@@ -52,35 +51,22 @@ func TestIntegration_StructuredOutput(t *testing.T) {
 	msg := domain.NewUserMessage(chatID, "I want to visit Italy. I'm on a budget and I love nature.")
 	t.Logf("Sending structured message to model gpt-4: %s", msg.Content)
 
-	schema := map[string]any{
-		"type": "object",
-		"properties": map[string]any{
-			"Destinations": map[string]string{"type": "string"},
-			"Preferences":  map[string]string{"type": "string"},
-			"Interest":     map[string]string{"type": "string"},
-		},
-		"required":             []string{"Destinations", "Preferences", "Interest"},
-		"additionalProperties": false,
+	type tripDetails struct {
+		Destinations []string `json:"destinations"`
+		Preferences  []string `json:"preferences"`
+		Interest     []string `json:"interest"`
 	}
 
-	response, err := client.StructuredOutput(ctx, []domain.Message{msg}, "gpt-4o", schema)
+	session := llm.NewLLMModelSession(client, "gpt-4o")
+	response, err := llm.StructuredOutput[tripDetails](ctx, session, []domain.Message{msg})
 	if err != nil {
 		t.Fatalf("StructuredOutput failed: %v", err)
 	}
+	t.Logf("Structured response: %+v", response)
 
-	t.Logf("Structured response content: %s", response.Content)
-
-	var decoded map[string]string
-	err = json.Unmarshal([]byte(response.Content), &decoded)
-	if err != nil {
-		t.Fatalf("Failed to parse JSON: %v", err)
-	}
-
-	t.Logf("Parsed JSON: %+v", decoded)
-
-	assert.NotEmpty(t, decoded["Destinations"])
-	assert.NotEmpty(t, decoded["Preferences"])
-	assert.NotEmpty(t, decoded["Interest"])
+	assert.NotEmpty(t, response.Destinations)
+	assert.NotEmpty(t, response.Preferences)
+	assert.NotEmpty(t, response.Interest)
 }
 
 func TestIntegration_StreamChat(t *testing.T) {